@@ -1,13 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"email-microservice/internal/address"
+	"email-microservice/internal/apikey"
+	"email-microservice/internal/config"
 	"email-microservice/internal/db"
+	"email-microservice/internal/graph"
 	"email-microservice/internal/models"
+	"email-microservice/internal/policy"
+	"email-microservice/internal/suppression"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -19,15 +30,23 @@ func main() {
 		log.Println("Warnung: .env Datei nicht gefunden. Lese Konfiguration aus Umgebung.")
 	}
 
+	// 'config' benötigt keine Datenbank und zeigt auch eine ungültige Konfiguration an.
+	if len(os.Args) >= 2 && os.Args[1] == "config" {
+		handleConfig()
+		return
+	}
+
 	// Erstellen des DB-Clients
-	dbCfg, err := db.Load()
+	cfg, err := config.Load(config.ServiceAdmin)
 	if err != nil {
-		log.Fatalf("Fehler beim Laden der DB-Konfiguration: %v", err)
+		log.Fatalf("Fehler beim Laden der Konfiguration: %v", err)
 	}
-	dbClient, err := db.NewClient(dbCfg.Driver, dbCfg.DSN)
+	dbClient, err := db.NewClient(cfg.DB.Driver, cfg.DB.DSN)
 	if err != nil {
 		log.Fatalf("Fehler beim Verbinden mit der Datenbank: %v", err)
 	}
+	// Geheimnisse wie Client-Secrets werden mit dem Master-Key verschlüsselt gespeichert.
+	dbClient.SetKeyring(cfg.DB.Keyring)
 
 	// Befehls-Parsing
 	if len(os.Args) < 2 {
@@ -44,6 +63,18 @@ func main() {
 		handleList(dbClient)
 	case "delete":
 		handleDelete(dbClient)
+	case "job":
+		handleJob(dbClient)
+	case "policy":
+		handlePolicy(dbClient)
+	case "suppression":
+		handleSuppression(dbClient)
+	case "tenant":
+		handleTenant(dbClient)
+	case "keys":
+		handleKeys(dbClient, cfg.DB.Keyring)
+	case "apikey":
+		handleAPIKey(dbClient)
 	default:
 		fmt.Printf("Unbekannter Befehl: %s\n", os.Args[1])
 		printUsage()
@@ -62,7 +93,13 @@ func handleInit(client *db.Client) {
 func handleAdd(client *db.Client) {
 	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
 	appTag := addCmd.String("tag", "", "Einzigartiger App-Tag (z.B. 'invoicing-system')")
-	email := addCmd.String("email", "", "E-Mail-Adresse des Absenders")
+	email := addCmd.String("email", "", "E-Mail-Adresse des Absenders (From)")
+	mailbox := addCmd.String("mailbox", "", "Optionales Postfach für den Versand (Standard: SENDER_EMAIL, mit -tenant: -email); weicht es von -email ab, wird als -email gesendet")
+	senderAddr := addCmd.String("sender", "", "Optionale Sender-Adresse für den Versand im Auftrag von -email")
+	tenantName := addCmd.String("tenant", "", "Optionaler Mandant, dessen Anmeldeinformationen verwendet werden (Standard: Umgebung)")
+	replyTo := addCmd.String("reply-to", "", "Optionale Standard-Reply-To-Adresse für Jobs dieses App-Tags")
+	importance := addCmd.String("importance", "", "Optionale Standard-Wichtigkeit: low, normal oder high")
+	saveToSent := addCmd.Bool("save-sent", true, "Gesendete Mails im Ordner 'Gesendete Elemente' speichern")
 	addCmd.Parse(os.Args[2:])
 
 	if *appTag == "" || *email == "" {
@@ -71,9 +108,48 @@ func handleAdd(client *db.Client) {
 		return
 	}
 
+	if !graph.ValidImportance(*importance) {
+		log.Fatalf("Ungültige Wichtigkeit '%s': erlaubt sind low, normal oder high.", *importance)
+	}
+	if *replyTo != "" {
+		if _, err := address.Parse(*replyTo); err != nil {
+			log.Fatalf("Ungültige Reply-To-Adresse: %v", err)
+		}
+	}
+	if *mailbox != "" {
+		if _, err := address.Parse(*mailbox); err != nil {
+			log.Fatalf("Ungültiges Postfach: %v", err)
+		}
+	}
+	if *senderAddr != "" {
+		if _, err := address.Parse(*senderAddr); err != nil {
+			log.Fatalf("Ungültige Sender-Adresse: %v", err)
+		}
+	}
+
+	var tenantID *int64
+	if *tenantName != "" {
+		tenant, err := client.GetTenantByName(*tenantName)
+		if err != nil {
+			log.Fatalf("Fehler beim Abrufen des Mandanten: %v", err)
+		}
+		tenantID = &tenant.ID
+		// SENDER_EMAIL gehört zum Tenant aus der Umgebung; ein Sender eines anderen Mandanten
+		// sendet daher immer über ein eigenes Postfach.
+		if *mailbox == "" {
+			*mailbox = *email
+		}
+	}
+
 	sender := models.Sender{
-		AppTag: *appTag,
-		Email:  *email,
+		AppTag:          *appTag,
+		TenantID:        tenantID,
+		Email:           *email,
+		Mailbox:         *mailbox,
+		SenderAddress:   *senderAddr,
+		ReplyTo:         *replyTo,
+		Importance:      *importance,
+		SaveToSentItems: *saveToSent,
 	}
 
 	id, err := client.Create("senders", sender)
@@ -96,10 +172,14 @@ func handleList(client *db.Client) {
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "ID\tAPP TAG\tEMAIL")
-	fmt.Fprintln(w, "--\t-------\t-----")
+	fmt.Fprintln(w, "ID\tAPP TAG\tMANDANT\tEMAIL\tPOSTFACH\tSENDER\tREPLY-TO\tWICHTIGKEIT\tGESENDETE SPEICHERN")
+	fmt.Fprintln(w, "--\t-------\t-------\t-----\t--------\t------\t--------\t-----------\t-------------------")
 	for _, s := range senders {
-		fmt.Fprintf(w, "%d\t%s\t%s\n", s.ID, s.AppTag, s.Email)
+		tenant := "-"
+		if s.TenantID != nil {
+			tenant = strconv.FormatInt(*s.TenantID, 10)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\n", s.ID, s.AppTag, tenant, s.Email, s.Mailbox, s.SenderAddress, s.ReplyTo, s.Importance, s.SaveToSentItems)
 	}
 	w.Flush()
 }
@@ -128,6 +208,652 @@ func handleDelete(client *db.Client) {
 	fmt.Printf("Sender mit App-Tag '%s' erfolgreich gelöscht.\n", *appTag)
 }
 
+// handleJob verwaltet einzelne E-Mail-Jobs, z.B. 'job cancel -id 42'.
+func handleJob(client *db.Client) {
+	if len(os.Args) < 3 {
+		fmt.Println("Verwendung: job <cancel|status> [argumente]")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "cancel":
+		handleJobCancel(client)
+	case "status":
+		handleJobStatus(client)
+	default:
+		fmt.Printf("Unbekannter Job-Befehl: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleJobCancel storniert einen noch nicht versendeten Job.
+func handleJobCancel(client *db.Client) {
+	cancelCmd := flag.NewFlagSet("job cancel", flag.ExitOnError)
+	jobID := cancelCmd.Int64("id", 0, "Die ID des zu stornierenden Jobs")
+	cancelCmd.Parse(os.Args[3:])
+
+	if *jobID <= 0 {
+		log.Println("Das Flag -id ist erforderlich.")
+		cancelCmd.Usage()
+		return
+	}
+
+	status, err := client.CancelJob(*jobID)
+	switch {
+	case errors.Is(err, db.ErrJobNotFound):
+		fmt.Printf("Kein Job mit der ID %d gefunden.\n", *jobID)
+		return
+	case errors.Is(err, db.ErrJobNotCancellable):
+		fmt.Printf("Job %d kann nicht mehr storniert werden (Status: %s).\n", *jobID, status)
+		os.Exit(1)
+	case err != nil:
+		log.Fatalf("Fehler beim Stornieren des Jobs: %v", err)
+	}
+	fmt.Printf("Job %d erfolgreich storniert.\n", *jobID)
+}
+
+// handleJobStatus zeigt den Status eines Jobs, bei Mail-Merge-Jobs pro Empfänger.
+func handleJobStatus(client *db.Client) {
+	statusCmd := flag.NewFlagSet("job status", flag.ExitOnError)
+	jobID := statusCmd.Int64("id", 0, "Die ID des Jobs")
+	statusCmd.Parse(os.Args[3:])
+
+	if *jobID <= 0 {
+		log.Println("Das Flag -id ist erforderlich.")
+		statusCmd.Usage()
+		return
+	}
+
+	info, err := client.GetJob(*jobID)
+	if errors.Is(err, db.ErrJobNotFound) {
+		fmt.Printf("Kein Job mit der ID %d gefunden.\n", *jobID)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Fehler beim Abrufen des Jobs: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tAPP TAG\tSTATUS\tEMPFÄNGER\tFEHLER")
+	fmt.Fprintln(w, "--\t-------\t------\t---------\t------")
+	for _, j := range append([]models.JobInfo{*info}, info.Children...) {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", j.ID, j.AppTag, j.Status, strings.Join(j.Recipients, ", "), j.ErrorMessage)
+	}
+	w.Flush()
+}
+
+// handlePolicy verwaltet die Empfänger-Regeln pro App-Tag, z.B. 'policy add -tag x -block gmail.com'.
+func handlePolicy(client *db.Client) {
+	if len(os.Args) < 3 {
+		fmt.Println("Verwendung: policy <add|list|delete> [argumente]")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "add":
+		handlePolicyAdd(client)
+	case "list":
+		handlePolicyList(client)
+	case "delete":
+		handlePolicyDelete(client)
+	default:
+		fmt.Printf("Unbekannter Policy-Befehl: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handlePolicyAdd fügt eine Allow- oder Block-Regel für einen App-Tag hinzu.
+func handlePolicyAdd(client *db.Client) {
+	addCmd := flag.NewFlagSet("policy add", flag.ExitOnError)
+	appTag := addCmd.String("tag", "", "Der App-Tag, für den die Regel gilt")
+	allow := addCmd.String("allow", "", "Erlaubte Domain oder Adresse, Wildcards mit '*' (z.B. '*.edeka.de')")
+	block := addCmd.String("block", "", "Gesperrte Domain oder Adresse, Wildcards mit '*' (z.B. '*@gmail.com')")
+	addCmd.Parse(os.Args[3:])
+
+	if *appTag == "" || (*allow == "") == (*block == "") {
+		log.Println("Das Flag -tag und genau eines der Flags -allow oder -block sind erforderlich.")
+		addCmd.Usage()
+		return
+	}
+
+	rule := models.PolicyRule{AppTag: *appTag, RuleType: policy.RuleAllow, Pattern: *allow}
+	if *block != "" {
+		rule.RuleType = policy.RuleBlock
+		rule.Pattern = *block
+	}
+	if err := policy.ValidatePattern(rule.Pattern); err != nil {
+		log.Fatalf("Ungültiges Muster: %v", err)
+	}
+
+	id, err := client.AddPolicyRule(rule)
+	if err != nil {
+		log.Fatalf("Fehler beim Hinzufügen der Regel: %v", err)
+	}
+	fmt.Printf("Regel erfolgreich mit ID %d hinzugefügt.\n", id)
+}
+
+// handlePolicyList zeigt die Regeln aller oder eines App-Tags an.
+func handlePolicyList(client *db.Client) {
+	listCmd := flag.NewFlagSet("policy list", flag.ExitOnError)
+	appTag := listCmd.String("tag", "", "Nur Regeln dieses App-Tags anzeigen")
+	listCmd.Parse(os.Args[3:])
+
+	rules, err := client.ListPolicyRules(*appTag)
+	if err != nil {
+		log.Fatalf("Fehler beim Abrufen der Regeln: %v", err)
+	}
+
+	if len(rules) == 0 {
+		fmt.Println("Keine Regeln in der Datenbank gefunden.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tAPP TAG\tTYP\tMUSTER")
+	fmt.Fprintln(w, "--\t-------\t---\t------")
+	for _, r := range rules {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.ID, r.AppTag, r.RuleType, r.Pattern)
+	}
+	w.Flush()
+}
+
+// handlePolicyDelete löscht eine Regel anhand ihrer ID.
+func handlePolicyDelete(client *db.Client) {
+	deleteCmd := flag.NewFlagSet("policy delete", flag.ExitOnError)
+	id := deleteCmd.Int64("id", 0, "Die ID der zu löschenden Regel")
+	deleteCmd.Parse(os.Args[3:])
+
+	if *id <= 0 {
+		log.Println("Das Flag -id ist erforderlich.")
+		deleteCmd.Usage()
+		return
+	}
+
+	rowsAffected, err := client.DeletePolicyRule(*id)
+	if err != nil {
+		log.Fatalf("Fehler beim Löschen der Regel: %v", err)
+	}
+	if rowsAffected == 0 {
+		fmt.Printf("Keine Regel mit der ID %d gefunden.\n", *id)
+		return
+	}
+	fmt.Printf("Regel %d erfolgreich gelöscht.\n", *id)
+}
+
+// handleTenant verwaltet die Mandanten (Microsoft-365-Tenants) mit ihren App-Anmeldeinformationen.
+func handleTenant(client *db.Client) {
+	if len(os.Args) < 3 {
+		fmt.Println("Verwendung: tenant <add|list|delete> [argumente]")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "add":
+		handleTenantAdd(client)
+	case "list":
+		handleTenantList(client)
+	case "delete":
+		handleTenantDelete(client)
+	default:
+		fmt.Printf("Unbekannter Tenant-Befehl: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleTenantAdd registriert einen Mandanten oder aktualisiert seine Anmeldeinformationen.
+func handleTenantAdd(client *db.Client) {
+	addCmd := flag.NewFlagSet("tenant add", flag.ExitOnError)
+	name := addCmd.String("name", "", "Eindeutiger Name des Mandanten (z.B. 'tochter-gmbh')")
+	tenantID := addCmd.String("tenant-id", "", "Die Azure-AD-Tenant-ID")
+	clientID := addCmd.String("client-id", "", "Die Client-ID der App-Registrierung")
+	secretFile := addCmd.String("client-secret-file", "", "Datei mit dem Client-Secret der App-Registrierung")
+	secretStdin := addCmd.Bool("client-secret-stdin", false, "Das Client-Secret von stdin lesen")
+	certPath := addCmd.String("cert", "", "Pfad zum PEM-Zertifikat für die Anmeldung per Zertifikat (statt eines Client-Secrets)")
+	keyPath := addCmd.String("key", "", "Pfad zum privaten PEM-Schlüssel des Zertifikats")
+	addCmd.Parse(os.Args[3:])
+
+	if *name == "" || *tenantID == "" || *clientID == "" {
+		log.Println("Die Flags -name, -tenant-id und -client-id sind erforderlich.")
+		addCmd.Usage()
+		return
+	}
+	if (*certPath == "") != (*keyPath == "") {
+		log.Fatalln("Die Flags -cert und -key müssen gemeinsam angegeben werden.")
+	}
+	if *secretFile != "" && *secretStdin {
+		log.Fatalln("Die Flags -client-secret-file und -client-secret-stdin schließen sich aus.")
+	}
+
+	// Das Secret wird nicht als Argument übergeben, damit es nicht in der Shell-Historie oder
+	// in der Prozessliste landet.
+	var clientSecret string
+	switch {
+	case *secretFile != "":
+		data, err := os.ReadFile(*secretFile)
+		if err != nil {
+			log.Fatalf("Fehler beim Lesen des Client-Secrets: %v", err)
+		}
+		clientSecret = strings.TrimSpace(string(data))
+	case *secretStdin:
+		clientSecret = readSecret()
+	}
+	if clientSecret == "" && *certPath == "" {
+		log.Fatalln("Entweder -client-secret-file, -client-secret-stdin oder -cert und -key sind erforderlich.")
+	}
+
+	id, err := client.AddTenant(models.Tenant{
+		Name:            *name,
+		AzureTenantID:   *tenantID,
+		ClientID:        *clientID,
+		ClientSecret:    clientSecret,
+		CertificatePath: *certPath,
+		PrivateKeyPath:  *keyPath,
+	})
+	if err != nil {
+		log.Fatalf("Fehler beim Hinzufügen des Mandanten: %v", err)
+	}
+	fmt.Printf("Mandant '%s' erfolgreich mit ID %d gespeichert.\n", *name, id)
+}
+
+// handleTenantList zeigt alle Mandanten an. Client-Secrets werden nicht ausgegeben.
+func handleTenantList(client *db.Client) {
+	tenants, err := client.ListTenants()
+	if err != nil {
+		log.Fatalf("Fehler beim Abrufen der Mandanten: %v", err)
+	}
+
+	if len(tenants) == 0 {
+		fmt.Println("Keine Mandanten in der Datenbank gefunden.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTENANT ID\tCLIENT ID\tANMELDUNG\tANGELEGT")
+	fmt.Fprintln(w, "--\t----\t---------\t---------\t---------\t--------")
+	for _, t := range tenants {
+		auth := "secret"
+		if t.CertificatePath != "" {
+			auth = "zertifikat (" + t.CertificatePath + ")"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", t.ID, t.Name, t.AzureTenantID, t.ClientID, auth, t.CreatedAt.Format(time.RFC3339))
+	}
+	w.Flush()
+}
+
+// handleTenantDelete löscht einen Mandanten, auf den kein Sender mehr verweist.
+func handleTenantDelete(client *db.Client) {
+	deleteCmd := flag.NewFlagSet("tenant delete", flag.ExitOnError)
+	name := deleteCmd.String("name", "", "Der Name des zu löschenden Mandanten")
+	deleteCmd.Parse(os.Args[3:])
+
+	if *name == "" {
+		log.Println("Das Flag -name ist erforderlich.")
+		deleteCmd.Usage()
+		return
+	}
+
+	rowsAffected, err := client.DeleteTenant(*name)
+	if err != nil {
+		log.Fatalf("Fehler beim Löschen des Mandanten (verweisen noch Sender darauf?): %v", err)
+	}
+	if rowsAffected == 0 {
+		fmt.Printf("Kein Mandant mit dem Namen '%s' gefunden.\n", *name)
+		return
+	}
+	fmt.Printf("Mandant '%s' erfolgreich gelöscht.\n", *name)
+}
+
+// handleAPIKey verwaltet die API-Keys der Clients.
+func handleAPIKey(client *db.Client) {
+	if len(os.Args) < 3 {
+		fmt.Println("Verwendung: apikey <issue|list|revoke> [argumente]")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "issue":
+		handleAPIKeyIssue(client)
+	case "list":
+		handleAPIKeyList(client)
+	case "revoke":
+		handleAPIKeyRevoke(client)
+	default:
+		fmt.Printf("Unbekannter API-Key-Befehl: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleAPIKeyIssue erzeugt einen API-Key für einen oder mehrere App-Tags. Der Key wird nur
+// einmal angezeigt; gespeichert wird lediglich sein Hash.
+func handleAPIKeyIssue(client *db.Client) {
+	issueCmd := flag.NewFlagSet("apikey issue", flag.ExitOnError)
+	name := issueCmd.String("name", "", "Eindeutiger Name des Clients (z.B. 'rechnungssystem-prod')")
+	tags := issueCmd.String("tags", "", "Kommagetrennte App-Tags, für die der Key senden darf")
+	suppressionAdmin := issueCmd.Bool("suppression-admin", false, "Key darf die globale Suppression-Liste über die API verwalten")
+	issueCmd.Parse(os.Args[3:])
+
+	var appTags []string
+	for _, t := range strings.Split(*tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			appTags = append(appTags, t)
+		}
+	}
+	if *suppressionAdmin {
+		appTags = append(appTags, apikey.ScopeSuppressionAdmin)
+	}
+	if *name == "" || len(appTags) == 0 {
+		log.Println("Die Flags -name und -tags (oder -suppression-admin) sind erforderlich.")
+		issueCmd.Usage()
+		return
+	}
+
+	key, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		log.Fatalf("Fehler beim Erzeugen des API-Keys: %v", err)
+	}
+	id, err := client.AddAPIKey(models.APIKey{Name: *name, Prefix: prefix, AppTags: appTags}, hash)
+	if err != nil {
+		log.Fatalf("Fehler beim Speichern des API-Keys: %v", err)
+	}
+
+	fmt.Printf("API-Key '%s' mit ID %d für die App-Tags %s erstellt.\n", *name, id, strings.Join(appTags, ", "))
+	fmt.Println("Der Key wird nur jetzt angezeigt und kann nicht wiederhergestellt werden:")
+	fmt.Println(key)
+}
+
+// handleAPIKeyList zeigt alle API-Keys mit Präfix, App-Tags und letzter Nutzung an.
+func handleAPIKeyList(client *db.Client) {
+	keys, err := client.ListAPIKeys()
+	if err != nil {
+		log.Fatalf("Fehler beim Abrufen der API-Keys: %v", err)
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("Keine API-Keys in der Datenbank gefunden.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPRÄFIX\tAPP TAGS\tANGELEGT\tZULETZT GENUTZT\tWIDERRUFEN")
+	fmt.Fprintln(w, "--\t----\t------\t--------\t--------\t---------------\t----------")
+	for _, k := range keys {
+		lastUsed, revoked := "-", "-"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format(time.RFC3339)
+		}
+		if k.RevokedAt != nil {
+			revoked = k.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s…\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.Prefix, strings.Join(k.AppTags, ","), k.CreatedAt.Format(time.RFC3339), lastUsed, revoked)
+	}
+	w.Flush()
+}
+
+// handleAPIKeyRevoke widerruft einen API-Key anhand seines Namens.
+func handleAPIKeyRevoke(client *db.Client) {
+	revokeCmd := flag.NewFlagSet("apikey revoke", flag.ExitOnError)
+	name := revokeCmd.String("name", "", "Der Name des zu widerrufenden API-Keys")
+	revokeCmd.Parse(os.Args[3:])
+
+	if *name == "" {
+		log.Println("Das Flag -name ist erforderlich.")
+		revokeCmd.Usage()
+		return
+	}
+
+	rowsAffected, err := client.RevokeAPIKey(*name)
+	if err != nil {
+		log.Fatalf("Fehler beim Widerrufen des API-Keys: %v", err)
+	}
+	if rowsAffected == 0 {
+		fmt.Printf("Kein aktiver API-Key mit dem Namen '%s' gefunden.\n", *name)
+		return
+	}
+	fmt.Printf("API-Key '%s' erfolgreich widerrufen.\n", *name)
+}
+
+// handleKeys verwaltet den Master-Key für die Verschlüsselung von Geheimnissen.
+func handleKeys(client *db.Client, keyring *db.Keyring) {
+	if len(os.Args) < 3 {
+		fmt.Println("Verwendung: keys <generate|encrypt|rotate>")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "generate":
+		key, err := db.GenerateMasterKey()
+		if err != nil {
+			log.Fatalf("Fehler beim Erzeugen des Master-Keys: %v", err)
+		}
+		fmt.Println(key)
+	case "encrypt":
+		handleKeysEncrypt(keyring)
+	case "rotate":
+		count, err := client.RotateTenantSecrets()
+		if err != nil {
+			log.Fatalf("Fehler beim Neuverschlüsseln: %v", err)
+		}
+		fmt.Printf("%d Client-Secrets mit dem aktuellen Master-Key neu verschlüsselt.\n", count)
+	default:
+		fmt.Printf("Unbekannter Keys-Befehl: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleKeysEncrypt liest ein Geheimnis von stdin und gibt es verschlüsselt aus, z.B. für CLIENT_SECRET.
+// Das Geheimnis wird nicht als Argument übergeben, damit es nicht in der Shell-Historie landet.
+func handleKeysEncrypt(keyring *db.Keyring) {
+	encrypted, err := keyring.Encrypt(readSecret())
+	if err != nil {
+		log.Fatalf("Fehler beim Verschlüsseln: %v", err)
+	}
+	fmt.Println(encrypted)
+}
+
+// readSecret liest ein Geheimnis aus der ersten Zeile von stdin.
+func readSecret() string {
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		log.Fatalln("Kein Geheimnis auf stdin gefunden.")
+	}
+	secret := strings.TrimSpace(scanner.Text())
+	if secret == "" {
+		log.Fatalln("Das Geheimnis darf nicht leer sein.")
+	}
+	return secret
+}
+
+// handleConfig zeigt die wirksame Konfiguration aus Datei (CONFIG_FILE), Umgebung und
+// *_FILE-Variablen an und prüft sie für einen Dienst.
+func handleConfig() {
+	if len(os.Args) < 3 || os.Args[2] != "print" {
+		fmt.Println("Verwendung: config print [-redacted] [-service api|worker|bounce|admin]")
+		os.Exit(1)
+	}
+
+	printCmd := flag.NewFlagSet("config print", flag.ExitOnError)
+	redacted := printCmd.Bool("redacted", false, "Geheimnisse wie CLIENT_SECRET, DB_DSN und MASTER_KEY durch [REDACTED] ersetzen")
+	serviceName := printCmd.String("service", "worker", "Dienst, für den die Konfiguration geprüft wird (api, worker, bounce oder admin)")
+	printCmd.Parse(os.Args[3:])
+
+	service, err := config.ParseService(*serviceName)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	cfg, readErr := config.Read()
+	if err := cfg.Print(os.Stdout, *redacted); err != nil {
+		log.Fatalf("Fehler bei der Ausgabe der Konfiguration: %v", err)
+	}
+	if err := errors.Join(readErr, cfg.Validate(service)); err != nil {
+		fmt.Fprintf(os.Stderr, "\nDie Konfiguration ist für %s ungültig:\n%v\n", *serviceName, err)
+		os.Exit(1)
+	}
+}
+
+// handleSuppression verwaltet die globale Suppression-Liste.
+func handleSuppression(client *db.Client) {
+	if len(os.Args) < 3 {
+		fmt.Println("Verwendung: suppression <add|remove|list|import|export> [argumente]")
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "add":
+		handleSuppressionAdd(client)
+	case "remove":
+		handleSuppressionRemove(client)
+	case "list":
+		handleSuppressionList(client)
+	case "import":
+		handleSuppressionImport(client)
+	case "export":
+		handleSuppressionExport(client)
+	default:
+		fmt.Printf("Unbekannter Suppression-Befehl: %s\n", os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// handleSuppressionAdd setzt eine Adresse auf die Suppression-Liste.
+func handleSuppressionAdd(client *db.Client) {
+	addCmd := flag.NewFlagSet("suppression add", flag.ExitOnError)
+	email := addCmd.String("email", "", "Die zu sperrende E-Mail-Adresse")
+	reason := addCmd.String("reason", suppression.ReasonManual, "Grund: hard_bounce, unsubscribe, complaint oder manual")
+	expires := addCmd.Duration("expires", 0, "Optionale Gültigkeitsdauer (z.B. 720h), 0 = unbegrenzt")
+	addCmd.Parse(os.Args[3:])
+
+	if *email == "" {
+		log.Println("Das Flag -email ist erforderlich.")
+		addCmd.Usage()
+		return
+	}
+
+	entry := models.Suppression{Address: *email, Reason: *reason, Source: suppression.SourceAdmin}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires)
+		entry.ExpiresAt = &expiresAt
+	}
+	if err := suppression.Normalize(&entry); err != nil {
+		log.Fatalf("Ungültiger Eintrag: %v", err)
+	}
+
+	if _, err := client.AddSuppression(entry); err != nil {
+		log.Fatalf("Fehler beim Hinzufügen zur Suppression-Liste: %v", err)
+	}
+	fmt.Printf("Adresse '%s' erfolgreich auf die Suppression-Liste gesetzt.\n", entry.Address)
+}
+
+// handleSuppressionRemove entfernt eine Adresse von der Suppression-Liste.
+func handleSuppressionRemove(client *db.Client) {
+	removeCmd := flag.NewFlagSet("suppression remove", flag.ExitOnError)
+	email := removeCmd.String("email", "", "Die zu entfernende E-Mail-Adresse")
+	removeCmd.Parse(os.Args[3:])
+
+	if *email == "" {
+		log.Println("Das Flag -email ist erforderlich.")
+		removeCmd.Usage()
+		return
+	}
+	addr, err := address.Parse(*email)
+	if err != nil {
+		log.Fatalf("Ungültige Adresse: %v", err)
+	}
+
+	rowsAffected, err := client.RemoveSuppression(addr.Key())
+	if err != nil {
+		log.Fatalf("Fehler beim Entfernen von der Suppression-Liste: %v", err)
+	}
+	if rowsAffected == 0 {
+		fmt.Printf("Adresse '%s' steht nicht auf der Suppression-Liste.\n", addr.Key())
+		return
+	}
+	fmt.Printf("Adresse '%s' erfolgreich von der Suppression-Liste entfernt.\n", addr.Key())
+}
+
+// handleSuppressionList zeigt die Einträge der Suppression-Liste an.
+func handleSuppressionList(client *db.Client) {
+	listCmd := flag.NewFlagSet("suppression list", flag.ExitOnError)
+	all := listCmd.Bool("all", false, "Auch abgelaufene Einträge anzeigen")
+	listCmd.Parse(os.Args[3:])
+
+	entries, err := client.ListSuppressions(*all)
+	if err != nil {
+		log.Fatalf("Fehler beim Abrufen der Suppression-Liste: %v", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Keine Einträge auf der Suppression-Liste gefunden.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ADRESSE\tGRUND\tQUELLE\tLÄUFT AB")
+	fmt.Fprintln(w, "-------\t-----\t------\t--------")
+	for _, e := range entries {
+		expiresAt := "nie"
+		if e.ExpiresAt != nil {
+			expiresAt = e.ExpiresAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Address, e.Reason, e.Source, expiresAt)
+	}
+	w.Flush()
+}
+
+// handleSuppressionImport importiert Einträge aus einer CSV-Datei (address,reason,source,expires_at).
+func handleSuppressionImport(client *db.Client) {
+	importCmd := flag.NewFlagSet("suppression import", flag.ExitOnError)
+	file := importCmd.String("file", "", "Pfad zur CSV-Datei")
+	importCmd.Parse(os.Args[3:])
+
+	if *file == "" {
+		log.Println("Das Flag -file ist erforderlich.")
+		importCmd.Usage()
+		return
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Fehler beim Öffnen der Datei: %v", err)
+	}
+	defer f.Close()
+
+	entries, err := suppression.ReadCSV(f, suppression.SourceImport)
+	if err != nil {
+		log.Fatalf("Fehler beim Lesen der CSV-Datei: %v", err)
+	}
+	count, err := client.ImportSuppressions(entries)
+	if err != nil {
+		log.Fatalf("Fehler beim Import: %v", err)
+	}
+	fmt.Printf("%d Einträge erfolgreich importiert.\n", count)
+}
+
+// handleSuppressionExport schreibt alle Einträge als CSV in eine Datei oder auf stdout.
+func handleSuppressionExport(client *db.Client) {
+	exportCmd := flag.NewFlagSet("suppression export", flag.ExitOnError)
+	file := exportCmd.String("file", "", "Pfad zur CSV-Datei (Standard: stdout)")
+	all := exportCmd.Bool("all", false, "Auch abgelaufene Einträge exportieren")
+	exportCmd.Parse(os.Args[3:])
+
+	entries, err := client.ListSuppressions(*all)
+	if err != nil {
+		log.Fatalf("Fehler beim Abrufen der Suppression-Liste: %v", err)
+	}
+
+	out := os.Stdout
+	if *file != "" {
+		out, err = os.Create(*file)
+		if err != nil {
+			log.Fatalf("Fehler beim Erstellen der Datei: %v", err)
+		}
+		defer out.Close()
+	}
+	if err := suppression.WriteCSV(out, entries); err != nil {
+		log.Fatalf("Fehler beim Export: %v", err)
+	}
+}
+
 // printUsage wurde angepasst.
 func printUsage() {
 	fmt.Println("Admin-Tool zur Verwaltung der E-Mail-Sender-Datenbank.")
@@ -138,6 +864,16 @@ func printUsage() {
 	fmt.Println("  add           Fügt einen neuen Sender hinzu.")
 	fmt.Println("  list          Zeigt alle vorhandenen Sender an.")
 	fmt.Println("  delete        Löscht einen Sender.")
+	fmt.Println("  job cancel    Storniert einen noch nicht versendeten Job.")
+	fmt.Println("  job status    Zeigt den Status eines Jobs (bei Mail-Merge pro Empfänger).")
+	fmt.Println("  policy add    Fügt eine Allow- oder Block-Regel für Empfänger eines App-Tags hinzu.")
+	fmt.Println("  policy list   Zeigt die Empfänger-Regeln an.")
+	fmt.Println("  policy delete Löscht eine Empfänger-Regel.")
+	fmt.Println("  suppression   Verwaltet die Suppression-Liste (add, remove, list, import, export).")
+	fmt.Println("  tenant        Verwaltet Mandanten mit eigenen App-Anmeldeinformationen (add, list, delete).")
+	fmt.Println("  keys          Verwaltet den Master-Key (generate, encrypt, rotate).")
+	fmt.Println("  apikey        Verwaltet die API-Keys der Clients (issue, list, revoke).")
+	fmt.Println("  config print  Zeigt die wirksame Konfiguration an und prüft sie (-redacted blendet Geheimnisse aus).")
 	fmt.Println("\nFür Hilfe zu einem Befehl, rufen Sie ihn ohne Argumente auf, z.B.:")
 	fmt.Println("  go run ./cmd/admin/main.go add")
 }
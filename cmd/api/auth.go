@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"email-microservice/internal/apikey"
+	"email-microservice/internal/db"
+	"email-microservice/internal/jwtauth"
+	"email-microservice/internal/logging"
+)
+
+// apiKeyHeader ist der alternative Header für den API-Key, falls kein Authorization-Header gesetzt ist.
+const apiKeyHeader = "X-API-Key"
+
+type contextKey int
+
+const principalContextKey contextKey = iota
+
+// principal ist der authentifizierte Aufrufer einer Anfrage, entweder über einen API-Key
+// oder über ein JWT. AppTags sind die App-Tags, für die er senden und Jobs lesen darf;
+// SuppressionAdmin erlaubt die Verwaltung der globalen Suppression-Liste.
+type principal struct {
+	Name             string
+	AppTags          []string
+	SuppressionAdmin bool
+}
+
+// authenticate lehnt Anfragen ohne gültige Anmeldung mit 401 ab. Akzeptiert werden API-Keys
+// ("Authorization: Bearer <key>" oder Header X-API-Key) und, falls verifier gesetzt ist, JWTs
+// als Bearer-Token. Der Aufrufer wird für die Handler im Request-Kontext abgelegt.
+func authenticate(dbClient *db.Client, verifier *jwtauth.Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := r.Header.Get(apiKeyHeader)
+		if auth := r.Header.Get("Authorization"); raw == "" && strings.HasPrefix(auth, "Bearer ") {
+			raw = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+		if raw == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="email-api"`)
+			http.Error(w, "Missing API key or bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		var p *principal
+		var status int
+		if verifier != nil && jwtauth.LooksLikeJWT(raw) {
+			p, status = authenticateJWT(verifier, raw)
+		} else {
+			p, status = authenticateAPIKey(dbClient, raw)
+		}
+		if p == nil {
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="email-api", error="invalid_token"`)
+				http.Error(w, "Invalid API key or bearer token", status)
+				return
+			}
+			http.Error(w, "Failed to authenticate request", status)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey, p)))
+	})
+}
+
+// authenticateAPIKey sucht einen API-Key und vermerkt seine Nutzung.
+func authenticateAPIKey(dbClient *db.Client, raw string) (*principal, int) {
+	if apikey.Validate(raw) != nil {
+		return nil, http.StatusUnauthorized
+	}
+	key, err := dbClient.GetAPIKeyByHash(apikey.Hash(raw))
+	if errors.Is(err, db.ErrAPIKeyNotFound) {
+		return nil, http.StatusUnauthorized
+	}
+	if err != nil {
+		logging.Errorf("Failed to look up API key: %v", err)
+		return nil, http.StatusInternalServerError
+	}
+
+	if err := dbClient.TouchAPIKey(key.ID); err != nil {
+		logging.Warnf("%v", err)
+	}
+	return &principal{Name: "apikey:" + key.Name, AppTags: key.AppTags, SuppressionAdmin: apikey.IsSuppressionAdmin(key.AppTags)}, 0
+}
+
+// authenticateJWT prüft ein JWT gegen die konfigurierte JWKS.
+func authenticateJWT(verifier *jwtauth.Verifier, raw string) (*principal, int) {
+	claims, err := verifier.Verify(raw)
+	if err != nil {
+		logging.Warnf("Rejected bearer token: %v", err)
+		return nil, http.StatusUnauthorized
+	}
+	return &principal{Name: "jwt:" + claims.Subject, AppTags: claims.AppTags, SuppressionAdmin: claims.Admin}, 0
+}
+
+// principalFromContext liefert den Aufrufer der Anfrage, den authenticate abgelegt hat.
+func principalFromContext(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalContextKey).(*principal)
+	return p
+}
+
+// allowsAppTag prüft, ob der Aufrufer der Anfrage für den App-Tag berechtigt ist.
+func allowsAppTag(r *http.Request, appTag string) bool {
+	p := principalFromContext(r.Context())
+	return p != nil && apikey.AllowsAppTag(p.AppTags, appTag)
+}
+
+// requireSuppressionAdmin lehnt Anfragen von Aufrufern ohne Berechtigung für die
+// Suppression-Liste mit 403 ab. Die Liste ist global und enthält Adressen aller Mandanten,
+// ein App-Tag allein genügt daher nicht.
+func requireSuppressionAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := principalFromContext(r.Context())
+		if p == nil || !p.SuppressionAdmin {
+			logging.FromContext(r.Context()).Warnf("Rejected suppression list access without admin scope")
+			http.Error(w, "Caller is not allowed to manage the suppression list", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
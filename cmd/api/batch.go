@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"email-microservice/internal/config"
+	"email-microservice/internal/db"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/metrics"
+	"email-microservice/internal/models"
+	natsclient "email-microservice/internal/nats"
+	"email-microservice/internal/ratelimit"
+	"email-microservice/internal/telemetry"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// maxBatchSize begrenzt die Anzahl der Jobs pro Batch-Anfrage.
+	maxBatchSize = 1000
+
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// batchItemResult beschreibt das Ergebnis für einen einzelnen Job einer Batch-Anfrage.
+type batchItemResult struct {
+	Index  int          `json:"index"`
+	JobID  int64        `json:"job_id,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+// batchResponse ist die Antwort auf POST /send-email/batch.
+type batchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []batchItemResult `json:"results"`
+}
+
+// batchItem ist ein dekodierter Job zusammen mit einem eventuellen Dekodierfehler.
+type batchItem struct {
+	job models.EmailJob
+	err error
+}
+
+// sendEmailBatchHandler nimmt viele Jobs in einer Anfrage entgegen, entweder als JSON-Array
+// oder als NDJSON-Stream (Content-Type application/x-ndjson). Jeder Job wird einzeln
+// validiert und asynchron publiziert; die Antwort enthält pro Eintrag die Job-ID oder den Fehler.
+func sendEmailBatchHandler(js nats.JetStreamContext, dbClient *db.Client, limiter *ratelimit.Limiter, apiCfg config.APIConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, int64(apiCfg.MaxBatchBodySize))
+		items, err := decodeBatch(r, int(apiCfg.MaxBatchLineSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Request body must not exceed %s", apiCfg.MaxBatchBodySize), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logger := logging.FromContext(r.Context())
+		results := make([]batchItemResult, len(items))
+		futures := make([]nats.PubAckFuture, len(items))
+		// limit ist das restriktivste Ergebnis der Rate-Limit-Prüfungen für die Antwort-Header,
+		// damit Retry-After gesetzt ist, sobald ein Eintrag abgelehnt wurde.
+		var limit *ratelimit.Result
+		rateLimited := 0
+		for i := range items {
+			results[i].Index = i
+			job := &items[i].job
+
+			if items[i].err != nil {
+				results[i].Error = items[i].err.Error()
+				continue
+			}
+			job.CorrelationID = correlationID(r)
+			jobLogger := logger.With(logging.FieldAppTag, job.AppTag)
+			if !allowsAppTag(r, job.AppTag) {
+				results[i].Error = fmt.Sprintf("Caller is not allowed to send for app tag '%s'", job.AppTag)
+				continue
+			}
+			if err := validateJob(job); err != nil {
+				_, results[i].Error, results[i].Fields = jobErrorDetails(err)
+				continue
+			}
+			if err := checkPolicy(dbClient, job); err != nil {
+				var pe *policyError
+				if !errors.As(err, &pe) {
+					jobLogger.Errorf("Failed to check recipient policy for batch job %d: %v", i, err)
+					results[i].Error = "Failed to queue email job"
+					continue
+				}
+				_, results[i].Error, results[i].Fields = jobErrorDetails(err)
+				continue
+			}
+			if res := allowJob(r, limiter, job); res != nil {
+				limit = moreRestrictive(limit, res)
+				if !res.Allowed {
+					results[i].Error = rateLimitMessage(res)
+					rateLimited++
+					continue
+				}
+			}
+
+			jobID, err := dbClient.CreateJob(job)
+			if err != nil {
+				jobLogger.Errorf("Failed to record batch job %d: %v", i, err)
+				results[i].Error = "Failed to queue email job"
+				continue
+			}
+			job.ID = jobID
+			jobLogger = jobLogger.With(logging.FieldJobID, job.ID)
+			subject := natsclient.SubjectForPriority(job.Priority)
+			ctx, span := telemetry.StartSpan(r.Context(), "nats.publish", "messaging.destination.name", subject, "job.id", job.ID)
+			injectTraceContext(ctx, job)
+
+			jobJSON, _ := json.Marshal(job)
+			future, err := js.PublishAsync(subject, jobJSON)
+			span.End(err)
+			if err != nil {
+				failBatchJob(jobLogger, dbClient, job.ID, err)
+				results[i].Error = "Failed to queue email job"
+				continue
+			}
+			futures[i] = future
+			results[i].JobID = job.ID
+		}
+
+		// Nur auf die Acks dieses Batches warten; PublishAsyncComplete gilt für die ganze
+		// Verbindung und damit auch für gleichzeitige Batches anderer Clients.
+		resp := batchResponse{Results: results}
+		deadline := time.NewTimer(apiCfg.BatchAckTimeout)
+		defer deadline.Stop()
+		timedOut := false
+		for i, future := range futures {
+			if future != nil {
+				if err := awaitPubAck(future, deadline.C, &timedOut); err != nil {
+					failBatchJob(logger.With(logging.FieldJobID, results[i].JobID), dbClient, results[i].JobID, err)
+					results[i].JobID = 0
+					results[i].Error = "Failed to queue email job"
+				}
+			}
+			if results[i].Error == "" {
+				resp.Accepted++
+				metrics.JobEvent(metrics.EventAccepted, items[i].job.AppTag, "")
+			} else {
+				resp.Rejected++
+			}
+		}
+
+		logger.Infof("Accepted batch with %d jobs, rejected %d", resp.Accepted, resp.Rejected)
+
+		setRateLimitHeaders(w, limit)
+		status := http.StatusAccepted
+		if resp.Accepted == 0 {
+			status = http.StatusBadRequest
+			if rateLimited > 0 {
+				status = http.StatusTooManyRequests
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// failBatchJob markiert einen bereits protokollierten Job als fehlgeschlagen, wenn er nicht publiziert werden konnte.
+func failBatchJob(logger *logging.Logger, dbClient *db.Client, jobID int64, err error) {
+	logger.Errorf("Failed to publish job %d to NATS: %v", jobID, err)
+	if err := dbClient.FinishJob(jobID, db.JobStatusFailed, "failed to publish job to NATS"); err != nil {
+		logger.Errorf("%v", err)
+	}
+}
+
+// awaitPubAck wartet auf das Ack eines Publish, höchstens bis deadline abläuft. Danach wird
+// für die übrigen Einträge nur noch geprüft, ob ihr Ack bereits vorliegt.
+func awaitPubAck(future nats.PubAckFuture, deadline <-chan time.Time, timedOut *bool) error {
+	if !*timedOut {
+		select {
+		case <-future.Ok():
+			return nil
+		case err := <-future.Err():
+			return err
+		case <-deadline:
+			*timedOut = true
+		}
+	}
+	select {
+	case <-future.Ok():
+		return nil
+	case err := <-future.Err():
+		return err
+	default:
+		return errors.New("no publish ack received")
+	}
+}
+
+// decodeBatch liest die Jobs einer Batch-Anfrage. Ein Eintrag, der kein gültiger Job ist
+// (z.B. falsche Feldtypen oder ein zu großes Array-Element), führt nur zu einem Fehler für
+// diesen Eintrag. Nur wenn der Body selbst kein JSON-Array bzw. kein lesbarer NDJSON-Stream
+// ist, wird die ganze Anfrage verworfen.
+func decodeBatch(r *http.Request, maxLineSize int) ([]batchItem, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == ndjsonContentType {
+		return decodeNDJSON(r.Body, maxLineSize)
+	}
+	return decodeJSONArray(r.Body, maxLineSize)
+}
+
+// decodeJSONArray liest ein JSON-Array Element für Element, damit ein ungültiger Job wie bei
+// NDJSON nur seinen eigenen Eintrag betrifft. maxItemSize begrenzt die Größe eines Elements.
+func decodeJSONArray(body io.Reader, maxItemSize int) ([]batchItem, error) {
+	dec := json.NewDecoder(body)
+	invalidBody := func(err error) error {
+		return fmt.Errorf("Invalid request body: expected a JSON array of email jobs: %w", err)
+	}
+	if tok, err := dec.Token(); err != nil {
+		return nil, invalidBody(err)
+	} else if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, invalidBody(errors.New("body is not an array"))
+	}
+
+	var items []batchItem
+	for dec.More() {
+		if len(items) == maxBatchSize {
+			return nil, fmt.Errorf("Batch must not contain more than %d email jobs", maxBatchSize)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, invalidBody(err)
+		}
+
+		var item batchItem
+		if len(raw) > maxItemSize {
+			item.err = fmt.Errorf("Email job must not exceed %d bytes", maxItemSize)
+		} else if err := json.Unmarshal(raw, &item.job); err != nil {
+			item.err = errors.New("Invalid JSON object")
+		}
+		items = append(items, item)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, invalidBody(err)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("Batch must contain at least one email job")
+	}
+	return items, nil
+}
+
+// decodeNDJSON liest einen Job pro Zeile; leere Zeilen werden übersprungen. maxLineSize
+// begrenzt die Größe einer Zeile (inkl. Anhängen).
+func decodeNDJSON(body io.Reader, maxLineSize int) ([]batchItem, error) {
+	var items []batchItem
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxBatchSize {
+			return nil, fmt.Errorf("Batch must not contain more than %d email jobs", maxBatchSize)
+		}
+
+		var item batchItem
+		if err := json.Unmarshal(line, &item.job); err != nil {
+			item.err = errors.New("Invalid JSON object")
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Invalid request body: %w", err)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("Batch must contain at least one email job")
+	}
+	return items, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"email-microservice/internal/logging"
+	"email-microservice/internal/telemetry"
+)
+
+// correlationHeader trägt die Korrelations-ID einer Anfrage. Alternativ wird X-Request-ID übernommen.
+const correlationHeader = "X-Correlation-ID"
+
+// maxCorrelationIDLength begrenzt übernommene IDs, damit Logs und Jobs nicht beliebig wachsen.
+const maxCorrelationIDLength = 128
+
+const correlationContextKey contextKey = principalContextKey + 1
+
+// withCorrelationID übernimmt die Korrelations-ID aus X-Correlation-ID bzw. X-Request-ID oder
+// erzeugt eine neue, gibt sie im Antwort-Header zurück und legt sie zusammen mit einem Logger
+// (Felder correlation_id und trace_id) im Request-Kontext ab.
+func withCorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationHeader)
+		if id == "" {
+			id = r.Header.Get("X-Request-ID")
+		}
+		if !validCorrelationID(id) {
+			id = newCorrelationID()
+		}
+		w.Header().Set(correlationHeader, id)
+
+		ctx := context.WithValue(r.Context(), correlationContextKey, id)
+		ctx = logging.NewContext(ctx, logging.With(
+			logging.FieldCorrelationID, id,
+			logging.FieldTraceID, telemetry.TraceID(ctx),
+		))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// correlationID liefert die Korrelations-ID der Anfrage.
+func correlationID(r *http.Request) string {
+	id, _ := r.Context().Value(correlationContextKey).(string)
+	return id
+}
+
+// validCorrelationID akzeptiert nur kurze IDs aus Buchstaben, Ziffern und ".-_:", da die ID
+// unverändert in Logs und Antwort-Header übernommen wird.
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,51 +1,92 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 
+	"email-microservice/internal/config"
+	"email-microservice/internal/db"
+	"email-microservice/internal/health"
+	"email-microservice/internal/jwtauth"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/metrics"
 	"email-microservice/internal/models"
 	natsclient "email-microservice/internal/nats"
+	"email-microservice/internal/ratelimit"
+	"email-microservice/internal/telemetry"
 
+	_ "github.com/lib/pq"
 	"github.com/nats-io/nats.go"
-	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
 func main() {
-	// 1. Datadog Tracer initialisieren
-	tracer.Start(
-		tracer.WithService("email-api"),
-		tracer.WithEnv(os.Getenv("DD_ENV")), // z.B. "development" oder "production"
-	)
-	defer tracer.Stop()
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := config.Load(config.ServiceAPI)
+	if err != nil {
+		logging.Fatalf("Failed to load configuration: %v", err)
 	}
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = nats.DefaultURL
+	// Die Werte wurden in config.Load geprüft.
+	logging.Setup(cfg.Log)
+
+	// 1. Tracing initialisieren (TELEMETRY_PROVIDER: datadog, otlp oder none)
+	stopTelemetry, err := telemetry.Start("email-api", cfg.Telemetry.Provider)
+	if err != nil {
+		logging.Fatalf("Failed to start telemetry: %v", err)
 	}
+	defer stopTelemetry()
+
+	nc, js := natsclient.Setup(cfg.NATS.URL)
 
-	_, js := natsclient.Setup(natsURL)
+	// Die Datenbank wird benötigt, um Jobs zu protokollieren und stornieren zu können.
+	dbClient, err := db.NewClient(cfg.DB.Driver, cfg.DB.DSN)
+	if err != nil {
+		logging.Fatalf("Failed to connect to database: %v", err)
+	}
 
-	// 2. HTTP-Router als Datadog-instrumentierten ServeMux erstellen
-	// KORREKTUR: NewServeMux verwenden, um den Router automatisch zu instrumentieren.
-	mux := httptrace.NewServeMux()
-	mux.HandleFunc("/send-email", sendEmailHandler(js))
+	// 2. HTTP-Router erstellen; telemetry.Handler erzeugt für jede Anfrage einen Server-Span.
+	mux := http.NewServeMux()
+	// Optional: JWTs (z.B. von Entra ID oder Keycloak) zusätzlich zu API-Keys akzeptieren.
+	var verifier *jwtauth.Verifier
+	if cfg.JWT.Enabled() {
+		verifier = jwtauth.NewVerifier(&cfg.JWT)
+		logging.Infof("JWT authentication enabled for issuer %s", cfg.JWT.Issuer)
+	}
+
+	// Optional: Limits pro Minute und Tagesquoten pro Client bzw. App-Tag, gezählt in NATS KV.
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled() {
+		if limiter, err = ratelimit.New(js, &cfg.RateLimit); err != nil {
+			logging.Fatalf("Failed to set up rate limiting: %v", err)
+		}
+		logging.Infof("Rate limiting enabled per %s", cfg.RateLimit.Scope)
+	}
 
-	log.Printf("API service starting on port %s", port)
-	// KORREKTUR: Den instrumentierten mux direkt übergeben.
-	log.Fatal(http.ListenAndServe(":"+port, mux))
+	// Alle Endpunkte erfordern einen API-Key (admin-tool apikey issue) oder ein gültiges JWT.
+	auth := func(h http.Handler) http.Handler { return withCorrelationID(authenticate(dbClient, verifier, h)) }
+	mux.Handle("/send-email", auth(sendEmailHandler(js, dbClient, limiter)))
+	mux.Handle("/send-email/batch", auth(sendEmailBatchHandler(js, dbClient, limiter, cfg.API)))
+	mux.Handle("/jobs/", auth(jobHandler(dbClient)))
+	// Die Suppression-Liste ist global; nur Keys mit Scope admin:suppressions bzw. JWTs mit
+	// JWT_ADMIN_ROLE dürfen sie lesen und ändern.
+	mux.Handle("/suppressions", auth(requireSuppressionAdmin(suppressionsHandler(dbClient))))
+	mux.Handle("/suppressions/", auth(requireSuppressionAdmin(suppressionHandler(dbClient, cfg.API.MaxImportSize))))
+	// /metrics, /healthz und /readyz sind für Scraper und Orchestrierung ohne Authentifizierung erreichbar.
+	mux.Handle("/metrics", metrics.Handler())
+	checker := health.NewChecker()
+	checker.AddLiveness("nats", health.NATSConnection(nc))
+	checker.AddReadiness("jetstream", health.Stream(js, natsclient.StreamName))
+	checker.AddReadiness("postgres", health.Ping(dbClient))
+	checker.Register(mux)
+
+	logging.Infof("API service starting on port %s", cfg.Port)
+	logging.Fatalf("%v", http.ListenAndServe(":"+cfg.Port, telemetry.Handler(mux)))
 }
 
-func sendEmailHandler(js nats.JetStreamContext) http.HandlerFunc {
+func sendEmailHandler(js nats.JetStreamContext, dbClient *db.Client, limiter *ratelimit.Limiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
@@ -57,31 +98,156 @@ func sendEmailHandler(js nats.JetStreamContext) http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		job.CorrelationID = correlationID(r)
+		logger := logging.FromContext(r.Context()).With(logging.FieldAppTag, job.AppTag)
+
+		if !allowsAppTag(r, job.AppTag) {
+			http.Error(w, fmt.Sprintf("Caller is not allowed to send for app tag '%s'", job.AppTag), http.StatusForbidden)
+			return
+		}
 
-		if len(job.Recipients) == 0 {
-			http.Error(w, "Recipients field is required and must not be empty", http.StatusBadRequest)
+		if err := validateJob(&job); err != nil {
+			writeJobError(w, err)
 			return
 		}
 
-		// 3. Trace-Kontext für die Weitergabe an den Worker vorbereiten
-		if span, ok := tracer.SpanFromContext(r.Context()); ok {
-			job.TraceContext = make(map[string]string)
-			carrier := tracer.TextMapCarrier(job.TraceContext)
-			// Injizieren des Kontexts in die Job-Struktur
-			if err := tracer.Inject(span.Context(), carrier); err != nil {
-				log.Printf("ERROR: Could not inject trace context: %v", err)
+		if err := checkPolicy(dbClient, &job); err != nil {
+			var pe *policyError
+			if !errors.As(err, &pe) {
+				logger.Errorf("Failed to check recipient policy: %v", err)
+				http.Error(w, "Failed to queue email job", http.StatusInternalServerError)
+				return
 			}
+			writeJobError(w, err)
+			return
+		}
+
+		res := allowJob(r, limiter, &job)
+		setRateLimitHeaders(w, res)
+		if res != nil && !res.Allowed {
+			http.Error(w, rateLimitMessage(res), http.StatusTooManyRequests)
+			return
 		}
 
+		// Den Job protokollieren, damit er bis zum Versand storniert werden kann.
+		jobID, err := dbClient.CreateJob(&job)
+		if err != nil {
+			logger.Errorf("Failed to record job: %v", err)
+			http.Error(w, "Failed to queue email job", http.StatusInternalServerError)
+			return
+		}
+		job.ID = jobID
+		logger = logger.With(logging.FieldJobID, job.ID)
+
+		// 3. Trace-Kontext des Publish-Spans für die Weitergabe an den Worker vorbereiten
+		subject := natsclient.SubjectForPriority(job.Priority)
+		ctx, span := telemetry.StartSpan(r.Context(), "nats.publish", "messaging.destination.name", subject, "job.id", job.ID)
+		injectTraceContext(ctx, &job)
+
 		jobJSON, _ := json.Marshal(job)
-		if _, err := js.Publish(natsclient.EmailToSend, jobJSON); err != nil {
-			log.Printf("ERROR: Failed to publish job to NATS: %v", err)
+		_, err = js.Publish(subject, jobJSON)
+		span.End(err)
+		if err != nil {
+			logger.Errorf("Failed to publish job %d to NATS: %v", job.ID, err)
+			if err := dbClient.FinishJob(job.ID, db.JobStatusFailed, "failed to publish job to NATS"); err != nil {
+				logger.Errorf("%v", err)
+			}
 			http.Error(w, "Failed to queue email job", http.StatusInternalServerError)
 			return
 		}
 
-		log.Printf("Accepted job to send email to: %s", strings.Join(job.Recipients, ", "))
+		metrics.JobEvent(metrics.EventAccepted, job.AppTag, "")
+
+		w.Header().Set("Location", fmt.Sprintf("/jobs/%d", job.ID))
+		if len(job.MergeRecipients) > 0 {
+			logger.Infof("Accepted mail merge job %d for %d recipients", job.ID, len(job.MergeRecipients))
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(fmt.Sprintf("Mail merge job %d accepted for %d recipients", job.ID, len(job.MergeRecipients))))
+			return
+		}
+
+		logger.Infof("Accepted job %d to send email to: %s", job.ID, strings.Join(job.Recipients, ", "))
 		w.WriteHeader(http.StatusAccepted)
-		w.Write([]byte(fmt.Sprintf("Email job accepted for recipients: %s", strings.Join(job.Recipients, ", "))))
+		w.Write([]byte(fmt.Sprintf("Email job %d accepted for recipients: %s", job.ID, strings.Join(job.Recipients, ", "))))
+	}
+}
+
+// injectTraceContext hängt den Span-Kontext an den Job, damit der Worker den Trace fortsetzen kann.
+func injectTraceContext(ctx context.Context, job *models.EmailJob) {
+	carrier := make(map[string]string)
+	telemetry.Inject(ctx, carrier)
+	if len(carrier) > 0 {
+		job.TraceContext = carrier
+	}
+}
+
+// jobHandler bedient /jobs/{id}: GET liefert den Status (bei Mail-Merge-Jobs pro Empfänger),
+// DELETE storniert einen noch nicht versendeten Job.
+func jobHandler(dbClient *db.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+			http.Error(w, "Only GET and DELETE requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		jobID, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/jobs/"), 10, 64)
+		if err != nil || jobID <= 0 {
+			http.Error(w, "Invalid job ID", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			getJob(w, r, dbClient, jobID)
+			return
+		}
+		cancelJob(w, r, dbClient, jobID)
+	}
+}
+
+func getJob(w http.ResponseWriter, r *http.Request, dbClient *db.Client, jobID int64) {
+	info, err := dbClient.GetJob(jobID)
+	// Jobs fremder App-Tags werden wie nicht vorhandene Jobs behandelt.
+	if errors.Is(err, db.ErrJobNotFound) || (err == nil && !allowsAppTag(r, info.AppTag)) {
+		http.Error(w, fmt.Sprintf("Job %d not found", jobID), http.StatusNotFound)
+		return
 	}
+	if err != nil {
+		logging.Errorf("Failed to read job %d: %v", jobID, err)
+		http.Error(w, "Failed to read email job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func cancelJob(w http.ResponseWriter, r *http.Request, dbClient *db.Client, jobID int64) {
+	info, err := dbClient.GetJob(jobID)
+	if errors.Is(err, db.ErrJobNotFound) || (err == nil && !allowsAppTag(r, info.AppTag)) {
+		http.Error(w, fmt.Sprintf("Job %d not found", jobID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Errorf("Failed to read job %d: %v", jobID, err)
+		http.Error(w, "Failed to cancel email job", http.StatusInternalServerError)
+		return
+	}
+
+	status, err := dbClient.CancelJob(jobID)
+	switch {
+	case errors.Is(err, db.ErrJobNotFound):
+		http.Error(w, fmt.Sprintf("Job %d not found", jobID), http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrJobNotCancellable):
+		http.Error(w, fmt.Sprintf("Job %d can no longer be cancelled (status: %s)", jobID, status), http.StatusConflict)
+		return
+	case err != nil:
+		logging.Errorf("Failed to cancel job %d: %v", jobID, err)
+		http.Error(w, "Failed to cancel email job", http.StatusInternalServerError)
+		return
+	}
+
+	logging.Infof("Cancelled job %d", jobID)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("Email job %d cancelled", jobID)))
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"email-microservice/internal/logging"
+	"email-microservice/internal/models"
+	"email-microservice/internal/ratelimit"
+)
+
+// jobCost ist die Anzahl der Jobs, die eine Anfrage verbraucht: bei Mail-Merge einer pro Empfänger.
+func jobCost(job *models.EmailJob) int {
+	if n := len(job.MergeRecipients); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// allowJob zählt einen Job gegen die Grenzen des Aufrufers bzw. App-Tags. Bei Fehlern des
+// KV-Stores wird der Job zugelassen, damit ein NATS-Problem nicht den Versand blockiert.
+func allowJob(r *http.Request, limiter *ratelimit.Limiter, job *models.EmailJob) *ratelimit.Result {
+	if limiter == nil {
+		return nil
+	}
+	id := job.AppTag
+	if limiter.Scope() == ratelimit.ScopeClient {
+		if p := principalFromContext(r.Context()); p != nil {
+			id = p.Name
+		}
+	}
+
+	res, err := limiter.Allow(id, jobCost(job))
+	if err != nil {
+		logging.FromContext(r.Context()).Errorf("Rate limit check failed, allowing request: %v", err)
+		return nil
+	}
+	return res
+}
+
+// setRateLimitHeaders setzt X-RateLimit-* für das Minutenlimit, X-Quota-* für die Tagesquote
+// und bei Überschreitung Retry-After.
+func setRateLimitHeaders(w http.ResponseWriter, res *ratelimit.Result) {
+	if res == nil {
+		return
+	}
+	if m := res.Minute; m != nil {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(m.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(m.Remaining, 0)))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(m.Reset.Unix(), 10))
+	}
+	if d := res.Daily; d != nil {
+		w.Header().Set("X-Quota-Limit", strconv.Itoa(d.Limit))
+		w.Header().Set("X-Quota-Remaining", strconv.Itoa(max(d.Remaining, 0)))
+		w.Header().Set("X-Quota-Reset", strconv.FormatInt(d.Reset.Unix(), 10))
+	}
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+	}
+}
+
+// moreRestrictive liefert von zwei Prüfergebnissen das restriktivere: Eine Ablehnung geht vor,
+// unter Ablehnungen die mit dem längsten Retry-After, sonst das mit den wenigsten
+// verbleibenden Jobs.
+func moreRestrictive(a, b *ratelimit.Result) *ratelimit.Result {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.Allowed != b.Allowed:
+		if !a.Allowed {
+			return a
+		}
+		return b
+	case !a.Allowed:
+		if b.RetryAfter > a.RetryAfter {
+			return b
+		}
+		return a
+	}
+	if remaining(b) < remaining(a) {
+		return b
+	}
+	return a
+}
+
+// remaining liefert die Anzahl der Jobs, die nach res noch zulässig sind.
+func remaining(res *ratelimit.Result) int {
+	n := math.MaxInt
+	for _, w := range []*ratelimit.Window{res.Minute, res.Daily} {
+		if w != nil {
+			n = min(n, w.Remaining)
+		}
+	}
+	return n
+}
+
+// rateLimitMessage beschreibt, welche Grenze überschritten wurde.
+func rateLimitMessage(res *ratelimit.Result) string {
+	if res.Daily != nil && res.Daily.Remaining < 0 {
+		return fmt.Sprintf("Daily quota of %d jobs exceeded", res.Daily.Limit)
+	}
+	return fmt.Sprintf("Rate limit of %d jobs per minute exceeded", res.Minute.Limit)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"email-microservice/internal/address"
+	"email-microservice/internal/config"
+	"email-microservice/internal/db"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/models"
+	"email-microservice/internal/suppression"
+)
+
+// suppressionsHandler bedient /suppressions: GET listet die Einträge (als JSON oder mit
+// ?format=csv als CSV-Export), POST fügt einen Eintrag hinzu.
+func suppressionsHandler(dbClient *db.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listSuppressions(w, r, dbClient)
+		case http.MethodPost:
+			addSuppression(w, r, dbClient)
+		default:
+			http.Error(w, "Only GET and POST requests are allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// suppressionHandler bedient /suppressions/{address} (DELETE) und /suppressions/import (POST, CSV).
+func suppressionHandler(dbClient *db.Client, maxImportSize config.Size) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, "/suppressions/")
+		if rest == "import" {
+			if r.Method != http.MethodPost {
+				http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			importSuppressions(w, r, dbClient, maxImportSize)
+			return
+		}
+
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Only DELETE requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		raw, err := url.PathUnescape(rest)
+		if err != nil {
+			http.Error(w, "Invalid address", http.StatusBadRequest)
+			return
+		}
+		addr, err := address.Parse(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rowsAffected, err := dbClient.RemoveSuppression(addr.Key())
+		if err != nil {
+			logging.Errorf("%v", err)
+			http.Error(w, "Failed to remove suppression", http.StatusInternalServerError)
+			return
+		}
+		if rowsAffected == 0 {
+			http.Error(w, fmt.Sprintf("Address %s is not suppressed", addr.Key()), http.StatusNotFound)
+			return
+		}
+		logging.Infof("Removed %s from suppression list", addr.Key())
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func listSuppressions(w http.ResponseWriter, r *http.Request, dbClient *db.Client) {
+	entries, err := dbClient.ListSuppressions(r.URL.Query().Get("include_expired") == "true")
+	if err != nil {
+		logging.Errorf("%v", err)
+		http.Error(w, "Failed to read suppressions", http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="suppressions.csv"`)
+		if err := suppression.WriteCSV(w, entries); err != nil {
+			logging.Errorf("Failed to export suppressions: %v", err)
+		}
+		return
+	}
+
+	if entries == nil {
+		entries = []models.Suppression{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func addSuppression(w http.ResponseWriter, r *http.Request, dbClient *db.Client) {
+	var entry models.Suppression
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	entry.Source = suppression.SourceAPI
+	if err := suppression.Normalize(&entry); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := dbClient.AddSuppression(entry)
+	if err != nil {
+		logging.Errorf("%v", err)
+		http.Error(w, "Failed to add suppression", http.StatusInternalServerError)
+		return
+	}
+	entry.ID = id
+
+	logging.Infof("Added %s to suppression list (reason: %s)", entry.Address, entry.Reason)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+func importSuppressions(w http.ResponseWriter, r *http.Request, dbClient *db.Client, maxImportSize config.Size) {
+	entries, err := suppression.ReadCSV(http.MaxBytesReader(w, r.Body, int64(maxImportSize)), suppression.SourceImport)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Request body must not exceed %s", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Invalid CSV: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	count, err := dbClient.ImportSuppressions(entries)
+	if err != nil {
+		logging.Errorf("%v", err)
+		http.Error(w, "Failed to import suppressions", http.StatusInternalServerError)
+		return
+	}
+
+	logging.Infof("Imported %d suppressions", count)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Imported int64 `json:"imported"`
+	}{Imported: count})
+}
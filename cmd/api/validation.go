@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"email-microservice/internal/address"
+	"email-microservice/internal/db"
+	"email-microservice/internal/graph"
+	"email-microservice/internal/merge"
+	"email-microservice/internal/models"
+	natsclient "email-microservice/internal/nats"
+	"email-microservice/internal/policy"
+)
+
+// maxRecipients ist die maximale Anzahl an Empfängern (To, Cc und Bcc zusammen) pro Nachricht.
+// Exchange Online lehnt Nachrichten mit mehr als 500 Empfängern ab.
+const maxRecipients = 500
+
+// fieldError beschreibt einen Validierungsfehler für ein einzelnes Feld, z.B. "cc_recipients[2]".
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationError sammelt alle Feldfehler eines Jobs.
+type validationError struct {
+	Fields []fieldError `json:"fields"`
+}
+
+func (e *validationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *validationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// policyError sammelt die Empfänger, die gegen die Policy des App-Tags verstoßen.
+type policyError struct {
+	Fields []fieldError `json:"fields"`
+}
+
+func (e *policyError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "recipient policy violation: " + strings.Join(msgs, "; ")
+}
+
+// writeJobError antwortet auf einen abgelehnten Job: Validierungsfehler mit 400,
+// Policy-Verstöße mit 403, jeweils als JSON mit allen betroffenen Feldern.
+// Sonstige Fehler werden als Text mit 400 ausgegeben.
+func writeJobError(w http.ResponseWriter, err error) {
+	status, message, fields := jobErrorDetails(err)
+	if fields == nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error  string       `json:"error"`
+		Fields []fieldError `json:"fields"`
+	}{Error: message, Fields: fields})
+}
+
+// jobErrorDetails liefert Statuscode, Meldung und Feldfehler zu einem abgelehnten Job.
+func jobErrorDetails(err error) (int, string, []fieldError) {
+	var ve *validationError
+	if errors.As(err, &ve) {
+		return http.StatusBadRequest, "validation failed", ve.Fields
+	}
+	var pe *policyError
+	if errors.As(err, &pe) {
+		return http.StatusForbidden, "recipient policy violation", pe.Fields
+	}
+	return http.StatusBadRequest, err.Error(), nil
+}
+
+// checkPolicy prüft alle Empfänger eines validierten Jobs gegen die Policy seines App-Tags.
+func checkPolicy(dbClient *db.Client, job *models.EmailJob) error {
+	rules, err := dbClient.ListPolicyRules(job.AppTag)
+	if err != nil {
+		return err
+	}
+	p := policy.FromRules(rules)
+
+	pe := &policyError{}
+	lists := []struct {
+		field      string
+		recipients []string
+	}{
+		{"recipients", job.Recipients},
+		{"cc_recipients", job.CcRecipients},
+		{"bcc_recipients", job.BccRecipients},
+	}
+	for _, l := range lists {
+		for _, v := range p.CheckAll(l.recipients) {
+			pe.Fields = append(pe.Fields, fieldError{Field: fmt.Sprintf("%s[%d]", l.field, v.Index), Message: v.Err.Error()})
+		}
+	}
+	for i, r := range job.MergeRecipients {
+		for _, v := range p.CheckAll([]string{r.Address}) {
+			pe.Fields = append(pe.Fields, fieldError{Field: fmt.Sprintf("merge_recipients[%d].address", i), Message: v.Err.Error()})
+		}
+	}
+
+	if len(pe.Fields) > 0 {
+		return pe
+	}
+	return nil
+}
+
+// validateJob prüft einen Job und normalisiert dabei die Empfängeradressen: Adressen werden mit
+// net/mail geparst, Anzeigenamen ("Name <a@b.de>") bleiben erhalten und Duplikate über To, Cc
+// und Bcc hinweg werden entfernt (die erste Nennung gewinnt). Alle Fehler werden gesammelt.
+func validateJob(job *models.EmailJob) error {
+	ve := &validationError{}
+	job.ParentID = 0
+
+	if len(job.MergeRecipients) > 0 {
+		validateMergeJob(job, ve)
+	} else {
+		seen := make(map[string]bool)
+		job.Recipients = normalizeRecipients("recipients", job.Recipients, seen, ve)
+		job.CcRecipients = normalizeRecipients("cc_recipients", job.CcRecipients, seen, ve)
+		job.BccRecipients = normalizeRecipients("bcc_recipients", job.BccRecipients, seen, ve)
+
+		if len(job.Recipients) == 0 && len(ve.Fields) == 0 {
+			ve.add("recipients", "is required and must not be empty")
+		}
+		if len(seen) > maxRecipients {
+			ve.add("recipients", "must not contain more than %d addresses across recipients, cc_recipients and bcc_recipients", maxRecipients)
+		}
+	}
+
+	if !natsclient.ValidPriority(job.Priority) {
+		ve.add("priority", "must be one of 'high', 'normal' or 'low'")
+	}
+	validateMessageOptions(job, ve)
+
+	if len(job.Headers) > graph.MaxCustomHeaders {
+		ve.add("headers", "must not contain more than %d entries", graph.MaxCustomHeaders)
+	}
+	for _, name := range sortedHeaderNames(job.Headers) {
+		if err := graph.ValidateHeader(name, job.Headers[name]); err != nil {
+			ve.add("headers."+name, "%v", err)
+		}
+	}
+	if job.IgnoreSuppression && job.Priority != natsclient.PriorityHigh {
+		ve.add("ignore_suppression", "is only allowed for transactional mail with priority 'high'")
+	}
+
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// maxCategories begrenzt die Anzahl der Outlook-Kategorien pro Nachricht.
+const maxCategories = 25
+
+// validateMessageOptions prüft Reply-To, Importance und Kategorien eines Jobs.
+func validateMessageOptions(job *models.EmailJob, ve *validationError) {
+	replyTo := make([]string, 0, len(job.ReplyTo))
+	for i, raw := range job.ReplyTo {
+		addr, err := address.Parse(raw)
+		if err != nil {
+			ve.add(fmt.Sprintf("reply_to[%d]", i), "%v", err)
+			continue
+		}
+		replyTo = append(replyTo, addr.String())
+	}
+	if len(job.ReplyTo) > 0 {
+		job.ReplyTo = replyTo
+	}
+
+	if !graph.ValidImportance(job.Importance) {
+		ve.add("importance", "must be one of 'low', 'normal' or 'high'")
+	}
+
+	if len(job.Categories) > maxCategories {
+		ve.add("categories", "must not contain more than %d entries", maxCategories)
+	}
+	for i, c := range job.Categories {
+		if strings.TrimSpace(c) == "" {
+			ve.add(fmt.Sprintf("categories[%d]", i), "must not be empty")
+		}
+	}
+}
+
+// sortedHeaderNames liefert die Header-Namen sortiert, damit Fehler in stabiler Reihenfolge erscheinen.
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeRecipients parst eine Empfängerliste und entfernt Adressen, die bereits in seen enthalten sind.
+func normalizeRecipients(field string, recipients []string, seen map[string]bool, ve *validationError) []string {
+	var normalized []string
+	for i, raw := range recipients {
+		addr, err := address.Parse(raw)
+		if err != nil {
+			ve.add(fmt.Sprintf("%s[%d]", field, i), "%v", err)
+			continue
+		}
+		if seen[addr.Key()] {
+			continue
+		}
+		seen[addr.Key()] = true
+		normalized = append(normalized, addr.String())
+	}
+	return normalized
+}
+
+// validateMergeJob prüft die Empfänger und Templates eines Mail-Merge-Jobs.
+func validateMergeJob(job *models.EmailJob, ve *validationError) {
+	if len(job.MergeRecipients) > merge.MaxRecipients {
+		ve.add("merge_recipients", "must not contain more than %d entries", merge.MaxRecipients)
+	}
+	if len(job.Recipients) > 0 || len(job.CcRecipients) > 0 || len(job.BccRecipients) > 0 {
+		ve.add("recipients", "recipients, cc_recipients and bcc_recipients must be empty for mail merge jobs")
+	}
+
+	seen := make(map[string]bool)
+	for i := range job.MergeRecipients {
+		field := fmt.Sprintf("merge_recipients[%d].address", i)
+		addr, err := address.Parse(job.MergeRecipients[i].Address)
+		if err != nil {
+			ve.add(field, "%v", err)
+			continue
+		}
+		if seen[addr.Key()] {
+			ve.add(field, "duplicate address %q", addr.Email)
+			continue
+		}
+		seen[addr.Key()] = true
+		job.MergeRecipients[i].Address = addr.String()
+	}
+
+	templates := []struct{ field, tmpl string }{
+		{"subject", job.Subject},
+		{"body_content", job.BodyContent},
+		{"html_body_content", job.HtmlBodyContent},
+	}
+	for _, t := range templates {
+		if err := merge.CheckTemplate(t.field, t.tmpl); err != nil {
+			ve.add(t.field, "%v", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"email-microservice/internal/bounce"
+	"email-microservice/internal/config"
+	"email-microservice/internal/db"
+	"email-microservice/internal/graph"
+	"email-microservice/internal/logging"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		logging.Warnf(".env file not found.")
+	}
+
+	cfg, err := config.Load(config.ServiceBounce)
+	if err != nil {
+		logging.Fatalf("Failed to load configuration: %v", err)
+	}
+	// Die Werte wurden in config.Load geprüft.
+	logging.Setup(cfg.Log)
+	// CLIENT_SECRET kann verschlüsselt sein (enc:v1:...); redigiert wird auch der Klartext,
+	// der erst für die Token-Anfrage entschlüsselt wird.
+	logging.RegisterSecret(cfg.ClientSecret)
+	if secret, err := cfg.DB.Keyring.Decrypt(cfg.ClientSecret); err != nil {
+		logging.Warnf("Could not decrypt CLIENT_SECRET: %v", err)
+	} else {
+		logging.RegisterSecret(secret)
+	}
+
+	dbClient, err := db.NewClient(cfg.DB.Driver, cfg.DB.DSN)
+	if err != nil {
+		logging.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	graphPool := graph.NewPool(cfg)
+
+	// Das Postfach aus SENDER_EMAIL wird immer mit abgefragt, da der Graph-Client darüber versendet.
+	processor := bounce.NewProcessor(graphPool, dbClient, cfg.Bounce.PollInterval, cfg.SenderEmail)
+	processor.Run()
+}
@@ -1,57 +1,92 @@
 package main
 
 import (
-	"log"
-	"os"
+	"net/http"
+	"time"
 
+	"email-microservice/internal/cache"
 	"email-microservice/internal/config"
 	"email-microservice/internal/db"
 	"email-microservice/internal/graph"
+	"email-microservice/internal/health"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/metrics"
 	natsclient "email-microservice/internal/nats"
+	"email-microservice/internal/telemetry"
 	"email-microservice/internal/worker"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
-	"github.com/nats-io/nats.go"
-	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
 )
 
-func main() {
-	// 1. Datadog Tracer initialisieren
-	tracer.Start(
-		tracer.WithService("email-worker"),
-		tracer.WithEnv(os.Getenv("DD_ENV")),
-	)
-	defer tracer.Stop()
+// maxFetchAge ist die Zeit ohne erfolgreichen Fetch, nach der der Worker als hängend gilt. Sie
+// liegt deutlich über der Dauer eines Jobs mit allen Versuchen und Wartezeiten bei 429.
+const maxFetchAge = 5 * time.Minute
 
+func main() {
 	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found.")
+		logging.Warnf(".env file not found.")
 	}
 
-	cfg, err := config.Load()
+	cfg, err := config.Load(config.ServiceWorker)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		logging.Fatalf("Failed to load configuration: %v", err)
+	}
+	// Die Werte wurden in config.Load geprüft.
+	logging.Setup(cfg.Log)
+	// CLIENT_SECRET kann verschlüsselt sein (enc:v1:...); redigiert wird auch der Klartext,
+	// der erst für die Token-Anfrage entschlüsselt wird.
+	logging.RegisterSecret(cfg.ClientSecret)
+	if secret, err := cfg.DB.Keyring.Decrypt(cfg.ClientSecret); err != nil {
+		logging.Warnf("Could not decrypt CLIENT_SECRET: %v", err)
+	} else {
+		logging.RegisterSecret(secret)
 	}
 
-	dbClient, err := db.NewClient(cfg.DB.Driver, cfg.DB.DSN)
+	// 1. Tracing initialisieren (TELEMETRY_PROVIDER: datadog, otlp oder none)
+	stopTelemetry, err := telemetry.Start("email-worker", cfg.Telemetry.Provider)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		logging.Fatalf("Failed to start telemetry: %v", err)
 	}
+	defer stopTelemetry()
 
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = nats.DefaultURL
+	dbClient, err := db.NewClient(cfg.DB.Driver, cfg.DB.DSN)
+	if err != nil {
+		logging.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	nc, js := natsclient.Setup(natsURL)
+	nc, js := natsclient.Setup(cfg.NATS.URL)
 	defer nc.Close()
 
-	graphClient := graph.NewClient(cfg)
+	// Ein Graph-Client pro Mandant; Sender ohne Mandant nutzen die Anmeldeinformationen aus der Umgebung.
+	graphPool := graph.NewPool(cfg)
+
+	// Sender, Mandanten und Empfänger-Regeln werden im Speicher gehalten und bei Änderungen
+	// (NOTIFY der Trigger aus 'admin-tool init') sowie alle CACHE_RESYNC_INTERVAL neu geladen.
+	configCache := cache.New(dbClient)
+	if err := configCache.Sync(); err != nil {
+		logging.Warnf("Could not load configuration cache, reading from the database until the next resync: %v", err)
+	}
+	go configCache.Watch(cfg.DB.DSN, cfg.Cache.ResyncInterval)
 
-	emailWorker, err := worker.New(js, graphClient, dbClient)
+	emailWorker, err := worker.New(js, graphPool, dbClient, configCache)
 	if err != nil {
-		log.Fatalf("Failed to create worker: %v", err)
+		logging.Fatalf("Failed to create worker: %v", err)
 	}
 
+	// /healthz prüft NATS-Verbindung und Fetch-Schleife, /readyz zusätzlich Stream, Datenbank
+	// und den Abruf eines Graph-Tokens mit den Standard-Anmeldeinformationen.
+	checker := health.NewChecker()
+	checker.AddLiveness("nats", health.NATSConnection(nc))
+	checker.AddLiveness("fetch", health.Recent(emailWorker.LastFetch, maxFetchAge))
+	checker.AddReadiness("jetstream", health.Stream(js, natsclient.StreamName))
+	checker.AddReadiness("postgres", health.Ping(dbClient))
+	checker.AddReadiness("graph_token", graphPool.Default().Authenticate)
+
+	// /metrics, /healthz und /readyz auf METRICS_ADDR (Standard :9090).
+	mux := http.NewServeMux()
+	checker.Register(mux)
+	metrics.Serve(cfg.Metrics.Addr, mux)
+
 	emailWorker.Run()
 }
@@ -0,0 +1,56 @@
+package address
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Address ist eine geparste E-Mail-Adresse mit optionalem Anzeigenamen.
+type Address struct {
+	Name  string
+	Email string
+}
+
+// Parse parst eine Adresse im Format "a@b.de" oder "Name <a@b.de>" und normalisiert sie:
+// Leerraum wird entfernt und die Domain kleingeschrieben. Der lokale Teil bleibt unverändert.
+func Parse(raw string) (Address, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Address{}, errors.New("address is empty")
+	}
+
+	parsed, err := mail.ParseAddress(raw)
+	if err != nil {
+		return Address{}, fmt.Errorf("invalid address %q: %w", raw, err)
+	}
+
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], strings.ToLower(parsed.Address[at+1:])
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return Address{}, fmt.Errorf("invalid address %q: domain %q is not fully qualified", raw, domain)
+	}
+
+	return Address{Name: strings.TrimSpace(parsed.Name), Email: local + "@" + domain}, nil
+}
+
+// Key liefert den Schlüssel zum Erkennen doppelter Adressen (Exchange vergleicht ohne Groß-/Kleinschreibung).
+func (a Address) Key() string {
+	return strings.ToLower(a.Email)
+}
+
+// Domain liefert die (kleingeschriebene) Domain der Adresse.
+func (a Address) Domain() string {
+	return a.Email[strings.LastIndex(a.Email, "@")+1:]
+}
+
+// String formatiert die Adresse so, dass Parse sie wieder einlesen kann. Anzeigenamen werden
+// immer in Anführungszeichen gesetzt, Umlaute bleiben dabei lesbar erhalten.
+func (a Address) String() string {
+	if a.Name == "" {
+		return a.Email
+	}
+	name := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a.Name)
+	return fmt.Sprintf("\"%s\" <%s>", name, a.Email)
+}
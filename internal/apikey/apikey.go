@@ -0,0 +1,72 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// keyPrefix kennzeichnet API-Keys dieses Dienstes, damit sie z.B. von Secret-Scannern erkannt werden.
+const keyPrefix = "ems_"
+
+// prefixLength ist die Länge des öffentlichen Präfixes, an dem ein Key in Listen erkennbar ist.
+const prefixLength = len(keyPrefix) + 8
+
+// ScopeSuppressionAdmin ist ein reservierter Scope neben den App-Tags eines Keys. Nur Keys mit
+// diesem Scope dürfen die globale Suppression-Liste über die API lesen und ändern.
+const ScopeSuppressionAdmin = "admin:suppressions"
+
+// ErrMalformed wird zurückgegeben, wenn ein Key nicht das Format dieses Dienstes hat.
+var ErrMalformed = errors.New("malformed API key")
+
+// Generate erzeugt einen neuen zufälligen API-Key. Zurückgegeben werden der Key (nur einmal
+// anzuzeigen), sein öffentliches Präfix und der SHA-256-Hash, der in der Datenbank gespeichert wird.
+func Generate() (key, prefix, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", err
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, key[:prefixLength], Hash(key), nil
+}
+
+// Hash liefert den SHA-256-Hash eines Keys als Hex-String. Da Keys 256 Bit Zufall enthalten,
+// ist ein schneller Hash ohne Salt ausreichend.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validate prüft das Format eines übergebenen Keys, bevor er in der Datenbank gesucht wird.
+func Validate(key string) error {
+	if !strings.HasPrefix(key, keyPrefix) || len(key) <= prefixLength {
+		return ErrMalformed
+	}
+	return nil
+}
+
+// AllowsAppTag prüft, ob ein Key mit den Scopes appTags für appTag senden darf. Reservierte
+// Scopes wie ScopeSuppressionAdmin sind keine App-Tags.
+func AllowsAppTag(appTags []string, appTag string) bool {
+	if appTag == ScopeSuppressionAdmin {
+		return false
+	}
+	return hasScope(appTags, appTag)
+}
+
+// IsSuppressionAdmin prüft, ob ein Key mit den Scopes appTags die Suppression-Liste verwalten darf.
+func IsSuppressionAdmin(appTags []string) bool {
+	return hasScope(appTags, ScopeSuppressionAdmin)
+}
+
+func hasScope(appTags []string, scope string) bool {
+	for _, t := range appTags {
+		if t == scope {
+			return true
+		}
+	}
+	return false
+}
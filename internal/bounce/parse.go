@@ -0,0 +1,153 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"email-microservice/internal/graph"
+)
+
+// ErrNotNDR wird zurückgegeben, wenn die Nachricht kein Unzustellbarkeitsbericht ist.
+var ErrNotNDR = errors.New("message is not a delivery status notification")
+
+// Recipient ist ein Empfänger aus dem Unzustellbarkeitsbericht.
+type Recipient struct {
+	Address    string
+	Status     string // z.B. "5.1.1"
+	Diagnostic string
+}
+
+// Permanent meldet, ob die Zustellung endgültig fehlgeschlagen ist (Status 5.x.x).
+func (r Recipient) Permanent() bool {
+	return strings.HasPrefix(r.Status, "5")
+}
+
+// Report ist ein geparster Unzustellbarkeitsbericht (RFC 3464).
+type Report struct {
+	// JobID stammt aus dem Header X-Job-ID der Originalnachricht; 0, wenn er fehlt.
+	JobID      int64
+	Recipients []Recipient
+}
+
+// Parse liest einen Unzustellbarkeitsbericht im MIME-Format (multipart/report mit
+// report-type=delivery-status). Ausgewertet werden die Empfänger mit Action "failed" aus
+// dem Teil message/delivery-status und der Header X-Job-ID der angehängten Originalnachricht
+// (message/rfc822 oder text/rfc822-headers).
+func Parse(raw []byte) (*Report, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrNotNDR
+	}
+
+	report := &Report{}
+	found, err := walk(textproto.MIMEHeader(msg.Header), msg.Body, report)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotNDR
+	}
+	return report, nil
+}
+
+// walk durchsucht einen MIME-Teil rekursiv nach einem multipart/report und wertet ihn aus.
+func walk(header textproto.MIMEHeader, body io.Reader, report *Report) (bool, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return false, nil
+	}
+
+	isReport := mediaType == "multipart/report" && strings.EqualFold(params["report-type"], "delivery-status")
+	reader := multipart.NewReader(body, params["boundary"])
+	found := false
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+
+		partBody := decodeTransferEncoding(part)
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch {
+		case isReport && partType == "message/delivery-status":
+			report.Recipients = append(report.Recipients, parseDeliveryStatus(partBody)...)
+			found = true
+		case isReport && (partType == "message/rfc822" || partType == "text/rfc822-headers"):
+			report.JobID = originalJobID(partBody)
+		case strings.HasPrefix(partType, "multipart/"):
+			ok, err := walk(part.Header, partBody, report)
+			if err != nil {
+				return false, err
+			}
+			found = found || ok
+		}
+	}
+	return found, nil
+}
+
+// parseDeliveryStatus liest die Empfängerblöcke eines message/delivery-status-Teils. Der erste
+// Block enthält die Felder zur Nachricht, jeder weitere die Felder eines Empfängers.
+func parseDeliveryStatus(body io.Reader) []Recipient {
+	reader := textproto.NewReader(bufio.NewReader(body))
+	var recipients []Recipient
+	for {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 && strings.EqualFold(fields.Get("Action"), "failed") {
+			if addr := recipientAddress(fields); addr != "" {
+				recipients = append(recipients, Recipient{
+					Address:    addr,
+					Status:     strings.TrimSpace(fields.Get("Status")),
+					Diagnostic: strings.TrimSpace(fields.Get("Diagnostic-Code")),
+				})
+			}
+		}
+		if err != nil {
+			return recipients
+		}
+	}
+}
+
+// recipientAddress liest die Adresse aus "Final-Recipient: rfc822; a@b.de" (ersatzweise Original-Recipient).
+func recipientAddress(fields textproto.MIMEHeader) string {
+	for _, name := range []string{"Final-Recipient", "Original-Recipient"} {
+		value := fields.Get(name)
+		if i := strings.Index(value, ";"); i >= 0 {
+			value = value[i+1:]
+		}
+		if value = strings.Trim(strings.TrimSpace(value), "<>"); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// originalJobID liest den Header X-Job-ID aus der angehängten Originalnachricht.
+func originalJobID(body io.Reader) int64 {
+	header, _ := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+	id, err := strconv.ParseInt(strings.TrimSpace(header.Get(graph.HeaderJobID)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// decodeTransferEncoding dekodiert base64-kodierte Teile. quoted-printable dekodiert
+// mime/multipart bereits selbst.
+func decodeTransferEncoding(part *multipart.Part) io.Reader {
+	if strings.EqualFold(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")), "base64") {
+		return base64.NewDecoder(base64.StdEncoding, part)
+	}
+	return part
+}
@@ -0,0 +1,262 @@
+package bounce
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"email-microservice/internal/address"
+	"email-microservice/internal/db"
+	"email-microservice/internal/graph"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/models"
+	"email-microservice/internal/suppression"
+)
+
+// ndrSenders sind lokale Teile von Absendern, die Unzustellbarkeitsberichte verschicken.
+var ndrSenders = []string{"postmaster", "mailer-daemon", "microsoftexchange"}
+
+// ndrSubjects sind typische Betreff-Präfixe von Unzustellbarkeitsberichten.
+var ndrSubjects = []string{"undeliverable", "unzustellbar", "delivery status notification", "mail delivery failed"}
+
+// Processor liest die Posteingänge der Sender-Postfächer per Graph-Delta-Abfrage, erkennt
+// Unzustellbarkeitsberichte, ordnet sie über den Header X-Job-ID den Jobs zu und setzt
+// endgültig nicht zustellbare Empfänger auf die Suppression-Liste.
+type Processor struct {
+	graphPool *graph.Pool
+	dbClient  *db.Client
+	interval  time.Duration
+	// extraMailboxes werden zusätzlich zu den Adressen aus der Tabelle 'senders' mit den
+	// Standard-Anmeldeinformationen gelesen.
+	extraMailboxes []string
+}
+
+// NewProcessor erstellt einen Processor, der alle interval die Postfächer abfragt.
+func NewProcessor(graphPool *graph.Pool, dbClient *db.Client, interval time.Duration, extraMailboxes ...string) *Processor {
+	return &Processor{
+		graphPool:      graphPool,
+		dbClient:       dbClient,
+		interval:       interval,
+		extraMailboxes: extraMailboxes,
+	}
+}
+
+// Run fragt die Postfächer in einer Endlosschleife ab.
+func (p *Processor) Run() {
+	logging.Infof("Bounce processor started. Polling sender mailboxes every %s...", p.interval)
+	for {
+		p.pollAll()
+		time.Sleep(p.interval)
+	}
+}
+
+func (p *Processor) pollAll() {
+	mailboxes, err := p.mailboxes()
+	if err != nil {
+		logging.Errorf("Could not determine sender mailboxes: %v", err)
+		return
+	}
+	for _, m := range mailboxes {
+		if err := p.pollMailbox(m); err != nil {
+			logging.Errorf("Could not process mailbox '%s': %v", m.Address, err)
+		}
+	}
+}
+
+// mailboxes liefert alle abzufragenden Postfächer ohne Duplikate.
+func (p *Processor) mailboxes() ([]db.SenderMailbox, error) {
+	senderMailboxes, err := p.dbClient.ListSenderMailboxes()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range p.extraMailboxes {
+		senderMailboxes = append(senderMailboxes, db.SenderMailbox{Address: m})
+	}
+
+	seen := make(map[string]bool)
+	var mailboxes []db.SenderMailbox
+	for _, m := range senderMailboxes {
+		key := strings.ToLower(strings.TrimSpace(m.Address))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		mailboxes = append(mailboxes, m)
+	}
+	return mailboxes, nil
+}
+
+// graphClientFor liefert den Graph-Client des Mandanten, zu dem das Postfach gehört.
+func (p *Processor) graphClientFor(m db.SenderMailbox) (*graph.Client, error) {
+	if m.TenantID == nil {
+		return p.graphPool.Default(), nil
+	}
+	tenant, err := p.dbClient.GetTenant(*m.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	return p.graphPool.Get(tenant), nil
+}
+
+// pollMailbox verarbeitet alle neuen Nachrichten eines Postfachs. Der Delta-Link wird erst
+// gespeichert, wenn alle Nachrichten verarbeitet sind, sodass ein vorübergehender Fehler zur
+// Wiederholung führt. Nachrichten, die sich dauerhaft nicht laden oder auswerten lassen, werden
+// übersprungen, damit das Postfach nicht bei jeder Abfrage am selben Bericht hängen bleibt.
+func (p *Processor) pollMailbox(m db.SenderMailbox) error {
+	mailbox := m.Address
+	graphClient, err := p.graphClientFor(m)
+	if err != nil {
+		return err
+	}
+
+	deltaLink, err := p.dbClient.GetDeltaLink(mailbox)
+	if err != nil {
+		return err
+	}
+
+	messages, nextDeltaLink, err := graphClient.InboxDelta(mailbox, deltaLink)
+	if err != nil {
+		return err
+	}
+
+	reports := 0
+	for _, msg := range messages {
+		if msg.Removed || !looksLikeNDR(msg) {
+			continue
+		}
+		processed, err := p.processMessage(graphClient, mailbox, msg)
+		if err != nil {
+			return err
+		}
+		if processed {
+			reports++
+		}
+	}
+
+	if reports > 0 {
+		logging.Infof("Processed %d non-delivery reports from mailbox '%s'", reports, mailbox)
+	}
+	return p.dbClient.SaveDeltaLink(mailbox, nextDeltaLink)
+}
+
+// processMessage lädt eine Kandidaten-Nachricht und wertet sie aus, falls es ein NDR ist.
+func (p *Processor) processMessage(graphClient *graph.Client, mailbox string, m graph.MessageSummary) (bool, error) {
+	raw, err := graphClient.MessageMIME(mailbox, m.ID)
+	if err != nil {
+		if skippable(err) {
+			logging.Warnf("Skipping message '%s' in mailbox '%s': %v", m.Subject, mailbox, err)
+			return false, nil
+		}
+		return false, err
+	}
+
+	report, err := Parse(raw)
+	if errors.Is(err, ErrNotNDR) {
+		return false, nil
+	}
+	if err != nil {
+		logging.Warnf("Could not parse non-delivery report '%s' in mailbox '%s': %v", m.Subject, mailbox, err)
+		return false, nil
+	}
+
+	for _, r := range report.Recipients {
+		if err := p.recordRecipient(report.JobID, r); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// recordRecipient vermerkt einen gebouncten Empfänger am Job und setzt ihn bei endgültigen
+// Fehlern auf die Suppression-Liste. Da jeder ein NDR-ähnliches Mail an das Postfach schicken
+// kann, wird ein Bericht nur ausgewertet, wenn X-Job-ID einen bekannten Job bezeichnet und die
+// Adresse einer seiner Empfänger war. Alles andere wird protokolliert und übersprungen.
+func (p *Processor) recordRecipient(jobID int64, r Recipient) error {
+	addr, err := address.Parse(r.Address)
+	if err != nil {
+		logging.Warnf("Ignoring bounced recipient with invalid address %q", r.Address)
+		return nil
+	}
+
+	if jobID == 0 {
+		logging.Warnf("Ignoring bounce of '%s' from non-delivery report without job ID", addr.Key())
+		return nil
+	}
+	recipients, err := p.dbClient.JobRecipients(jobID)
+	if errors.Is(err, db.ErrJobNotFound) {
+		logging.Warnf("Ignoring bounce of '%s' from non-delivery report for unknown job %d", addr.Key(), jobID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !containsAddress(recipients, addr) {
+		logging.Warnf("Ignoring bounce of '%s' from non-delivery report: not a recipient of job %d", addr.Key(), jobID)
+		return nil
+	}
+
+	if err := p.dbClient.RecordBounce(jobID, addr.Key()); err != nil {
+		return err
+	}
+
+	if !r.Permanent() {
+		logging.Infof("Transient delivery failure for '%s' (job %d, status %s)", addr.Key(), jobID, r.Status)
+		return nil
+	}
+
+	if _, err := p.dbClient.AddSuppression(models.Suppression{
+		Address: addr.Key(),
+		Reason:  suppression.ReasonHardBounce,
+		Source:  suppression.SourceNDR,
+	}); err != nil {
+		return err
+	}
+	logging.Infof("Suppressed '%s' after hard bounce (job %d, status %s)", addr.Key(), jobID, r.Status)
+	return nil
+}
+
+// containsAddress prüft, ob addr unter den protokollierten Empfängern eines Jobs ist.
+func containsAddress(recipients []string, addr address.Address) bool {
+	for _, raw := range recipients {
+		if a, err := address.Parse(raw); err == nil && a.Key() == addr.Key() {
+			return true
+		}
+	}
+	return false
+}
+
+// skippable meldet, ob eine Nachricht wegen err dauerhaft nicht geladen werden kann, etwa weil
+// sie zwischen Delta-Abfrage und Abruf gelöscht wurde (404) oder zu groß ist. Vorübergehende
+// Fehler sowie Authentifizierungs- und Berechtigungsfehler brechen die Abfrage dagegen ab.
+func skippable(err error) bool {
+	if errors.Is(err, graph.ErrMessageTooLarge) {
+		return true
+	}
+	var apiErr *graph.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 400 && !apiErr.Transient()
+	}
+	return false
+}
+
+// looksLikeNDR filtert Nachrichten anhand von Absender und Betreff vor, damit nur
+// wahrscheinliche Unzustellbarkeitsberichte vollständig geladen werden.
+func looksLikeNDR(m graph.MessageSummary) bool {
+	from := strings.ToLower(m.FromAddress)
+	if at := strings.Index(from, "@"); at >= 0 {
+		from = from[:at]
+	}
+	for _, s := range ndrSenders {
+		if from == s {
+			return true
+		}
+	}
+
+	subject := strings.ToLower(m.Subject)
+	for _, s := range ndrSubjects {
+		if strings.HasPrefix(subject, s) {
+			return true
+		}
+	}
+	return false
+}
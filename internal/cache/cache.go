@@ -0,0 +1,222 @@
+// Package cache hält Sender, Mandanten und Empfänger-Regeln für den Worker im Speicher, damit
+// nicht jeder Job die Datenbank abfragt. Änderungen meldet PostgreSQL über die Trigger aus
+// db.Migrate (NOTIFY auf db.ConfigChangedChannel); die betroffene Tabelle wird dann neu geladen.
+// Zusätzlich wird der Cache periodisch und nach jedem Verbindungsabbruch des Listeners
+// vollständig neu geladen, falls eine Benachrichtigung verloren ging.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"email-microservice/internal/db"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// listenerPingInterval ist der Abstand, in dem die Listener-Verbindung ohne Benachrichtigungen
+// geprüft wird, damit ein Abbruch bemerkt wird.
+const listenerPingInterval = 90 * time.Second
+
+// Cache enthält Sender nach App-Tag, Mandanten nach ID und Empfänger-Regeln nach App-Tag.
+// Fehlt ein Sender oder Mandant, wird er aus der Datenbank gelesen, sodass neue Einträge auch
+// ohne Benachrichtigung sofort verwendet werden. Ein so gelesener Eintrag wird nur übernommen,
+// wenn Reload die Tabelle währenddessen nicht ausgetauscht hat, da er sonst älter als der neu
+// geladene Stand sein kann.
+type Cache struct {
+	db *db.Client
+
+	mu       sync.RWMutex
+	senders  map[string]models.Sender
+	tenants  map[int64]models.Tenant
+	policies map[string][]models.PolicyRule
+	// senderGen und tenantGen werden bei jedem Reload der Tabelle erhöht.
+	senderGen uint64
+	tenantGen uint64
+	// policiesLoaded ist false, bis die Regeln einmal vollständig geladen wurden; bis dahin
+	// werden sie pro Job aus der Datenbank gelesen.
+	policiesLoaded bool
+}
+
+// New erstellt einen leeren Cache. Sync lädt die Einträge, Watch hält sie aktuell.
+func New(dbClient *db.Client) *Cache {
+	return &Cache{
+		db:       dbClient,
+		senders:  make(map[string]models.Sender),
+		tenants:  make(map[int64]models.Tenant),
+		policies: make(map[string][]models.PolicyRule),
+	}
+}
+
+// Sync lädt alle Tabellen neu.
+func (c *Cache) Sync() error {
+	for _, table := range db.ConfigTables {
+		if err := c.Reload(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reload lädt eine Tabelle aus db.ConfigTables neu.
+func (c *Cache) Reload(table string) error {
+	switch table {
+	case db.SendersTable:
+		var senders []models.Sender
+		if err := c.db.Read(db.SendersTable, &senders, ""); err != nil {
+			return fmt.Errorf("could not load senders: %w", err)
+		}
+		senderByAppTag := make(map[string]models.Sender, len(senders))
+		for _, s := range senders {
+			senderByAppTag[s.AppTag] = s
+		}
+		c.mu.Lock()
+		c.senders = senderByAppTag
+		c.senderGen++
+		c.mu.Unlock()
+
+	case db.TenantsTable:
+		tenants, err := c.db.ListTenants()
+		if err != nil {
+			return err
+		}
+		byID := make(map[int64]models.Tenant, len(tenants))
+		for _, t := range tenants {
+			byID[t.ID] = t
+		}
+		c.mu.Lock()
+		c.tenants = byID
+		c.tenantGen++
+		c.mu.Unlock()
+
+	case db.PoliciesTable:
+		rules, err := c.db.ListPolicyRules("")
+		if err != nil {
+			return err
+		}
+		byAppTag := make(map[string][]models.PolicyRule)
+		for _, r := range rules {
+			byAppTag[r.AppTag] = append(byAppTag[r.AppTag], r)
+		}
+		c.mu.Lock()
+		c.policies = byAppTag
+		c.policiesLoaded = true
+		c.mu.Unlock()
+
+	default:
+		return fmt.Errorf("unknown table %q", table)
+	}
+	return nil
+}
+
+// Sender liefert den Sender eines App-Tags.
+func (c *Cache) Sender(appTag string) (*models.Sender, error) {
+	if appTag == "" {
+		return nil, fmt.Errorf("appTag is empty")
+	}
+
+	c.mu.RLock()
+	s, ok := c.senders[appTag]
+	gen := c.senderGen
+	c.mu.RUnlock()
+	if ok {
+		return &s, nil
+	}
+
+	var senders []models.Sender
+	if err := c.db.Read(db.SendersTable, &senders, "app_tag = $1", appTag); err != nil {
+		return nil, fmt.Errorf("database query failed for appTag '%s': %w", appTag, err)
+	}
+	if len(senders) == 0 {
+		return nil, fmt.Errorf("no sender found for appTag '%s'", appTag)
+	}
+	c.mu.Lock()
+	if c.senderGen == gen {
+		c.senders[appTag] = senders[0]
+	}
+	c.mu.Unlock()
+	return &senders[0], nil
+}
+
+// Tenant liefert einen Mandanten anhand seiner ID.
+func (c *Cache) Tenant(id int64) (*models.Tenant, error) {
+	c.mu.RLock()
+	t, ok := c.tenants[id]
+	gen := c.tenantGen
+	c.mu.RUnlock()
+	if ok {
+		return &t, nil
+	}
+
+	tenant, err := c.db.GetTenant(id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.tenantGen == gen {
+		c.tenants[id] = *tenant
+	}
+	c.mu.Unlock()
+	return tenant, nil
+}
+
+// PolicyRules liefert die Empfänger-Regeln eines App-Tags.
+func (c *Cache) PolicyRules(appTag string) ([]models.PolicyRule, error) {
+	c.mu.RLock()
+	rules, loaded := c.policies[appTag], c.policiesLoaded
+	c.mu.RUnlock()
+	if loaded {
+		return rules, nil
+	}
+	return c.db.ListPolicyRules(appTag)
+}
+
+// Watch hält den Cache aktuell: Benachrichtigungen auf db.ConfigChangedChannel laden die
+// gemeldete Tabelle neu, alle resyncInterval sowie nach jeder Wiederverbindung werden alle
+// Tabellen neu geladen. Watch kehrt nicht zurück.
+func (c *Cache) Watch(dsn string, resyncInterval time.Duration) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logging.Warnf("Config change listener: %v", err)
+		}
+	})
+	if err := listener.Listen(db.ConfigChangedChannel); err != nil {
+		logging.Warnf("Could not listen for config changes, relying on periodic resync: %v", err)
+	}
+
+	resync := time.NewTicker(resyncInterval)
+	defer resync.Stop()
+	ping := time.NewTicker(listenerPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				// Die Verbindung wurde neu aufgebaut; Benachrichtigungen können verloren gegangen sein.
+				c.resync("listener reconnected")
+				continue
+			}
+			if err := c.Reload(n.Extra); err != nil {
+				logging.Errorf("Could not reload %s after change notification: %v", n.Extra, err)
+				continue
+			}
+			logging.Infof("Reloaded %s after change notification", n.Extra)
+		case <-resync.C:
+			c.resync("periodic resync")
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func (c *Cache) resync(reason string) {
+	if err := c.Sync(); err != nil {
+		logging.Errorf("Could not reload configuration cache (%s): %v", reason, err)
+		return
+	}
+	logging.Debugf("Reloaded configuration cache (%s)", reason)
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Quellen eines Werts, wie sie config print anzeigt.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+)
+
+// fileEnv ist die Umgebungsvariable mit dem Pfad der Konfigurationsdatei.
+const fileEnv = "CONFIG_FILE"
+
+// Die Felder der Konfiguration werden über Struct-Tags beschrieben:
+//
+//	config:"name"     Schlüssel in der Datei; verschachtelte Structs bilden Abschnitte ("db.dsn").
+//	                  config:",inline" übernimmt die Felder eines Structs ohne eigenen Abschnitt,
+//	                  config:"-" und Felder ohne Tag werden ignoriert.
+//	env:"NAME"        Umgebungsvariable, falls sie vom Schlüssel abweicht (Standard: DB_DSN für db.dsn).
+//	default:"wert"    Standardwert in derselben Schreibweise wie in der Umgebung.
+//	secret:"true"     Der Wert wird von config print --redacted nicht ausgegeben.
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// field ist ein einzelner Konfigurationswert.
+type field struct {
+	path   string
+	env    string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// node ist ein Abschnitt oder ein Wert im Baum der Konfiguration, in der Reihenfolge der Felder.
+type node struct {
+	name     string
+	field    *field
+	children []*node
+}
+
+// schema beschreibt alle Werte einer Config.
+type schema struct {
+	root     *node
+	fields   []*field
+	byPath   map[string]*field
+	sections map[string]bool
+}
+
+func newSchema(cfg *Config) *schema {
+	s := &schema{root: &node{}, byPath: make(map[string]*field), sections: make(map[string]bool)}
+	s.walk(s.root, "", reflect.ValueOf(cfg).Elem())
+	return s
+}
+
+func (s *schema) walk(parent *node, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("config")
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		name, opt, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+
+		if isSection(fv) {
+			if opt == "inline" {
+				s.walk(parent, prefix, fv)
+				continue
+			}
+			path := joinPath(prefix, name)
+			child := &node{name: name}
+			parent.children = append(parent.children, child)
+			s.sections[path] = true
+			s.walk(child, path, fv)
+			continue
+		}
+
+		f := &field{
+			path:   joinPath(prefix, name),
+			env:    sf.Tag.Get("env"),
+			def:    sf.Tag.Get("default"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		}
+		if f.env == "" {
+			f.env = strings.ToUpper(strings.ReplaceAll(f.path, ".", "_"))
+		}
+		parent.children = append(parent.children, &node{name: name, field: f})
+		s.fields = append(s.fields, f)
+		s.byPath[f.path] = f
+	}
+}
+
+// isSection meldet, ob ein Feld ein Abschnitt mit eigenen Werten ist und kein einzelner Wert.
+func isSection(v reflect.Value) bool {
+	return v.Kind() == reflect.Struct && v.Type() != durationType && !reflect.PointerTo(v.Type()).Implements(textUnmarshalerType)
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// Read liest die Konfiguration ohne sie zu prüfen: zuerst die Standardwerte, dann die Datei aus
+// CONFIG_FILE (YAML oder TOML), dann die Umgebungsvariablen und zuletzt <NAME>_FILE, z.B. für
+// Docker-Secrets. Alle Fehler werden gesammelt zurückgegeben; die Config ist immer gesetzt und
+// enthält alle lesbaren Werte.
+func Read() (*Config, error) {
+	cfg := &Config{sources: make(map[string]string)}
+	s := newSchema(cfg)
+	var errs []error
+
+	for _, f := range s.fields {
+		if f.def == "" {
+			continue
+		}
+		if err := setValue(f.value, f.def); err != nil {
+			panic(fmt.Sprintf("config: ungültiger Standardwert für %s: %v", f.path, err))
+		}
+		cfg.sources[f.path] = sourceDefault
+	}
+
+	if path := os.Getenv(fileEnv); path != "" {
+		data, err := readFile(path)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			errs = append(errs, s.applyFile(cfg, path, "", data)...)
+		}
+	}
+
+	for _, f := range s.fields {
+		raw, source, err := lookupEnv(f.env)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if source == "" {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
+		cfg.sources[f.path] = source
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// lookupEnv liest NAME oder den Inhalt der Datei aus NAME_FILE. source ist der Name der
+// verwendeten Variable oder leer, falls keine gesetzt ist.
+func lookupEnv(name string) (value, source string, err error) {
+	value = os.Getenv(name)
+	path := os.Getenv(name + "_FILE")
+	switch {
+	case value != "" && path != "":
+		return "", "", fmt.Errorf("%s und %s_FILE dürfen nicht gemeinsam gesetzt sein", name, name)
+	case value != "":
+		return value, name, nil
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), name + "_FILE", nil
+	}
+	return "", "", nil
+}
+
+// readFile liest eine YAML- (.yaml, .yml) oder TOML-Datei (.toml).
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("konfigurationsdatei konnte nicht gelesen werden: %w", err)
+	}
+	values := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("konfigurationsdatei %s: unbekanntes Format %q, erlaubt sind .yaml, .yml und .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("konfigurationsdatei %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func (s *schema) applyFile(cfg *Config, file, prefix string, data map[string]any) []error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		path := joinPath(prefix, k)
+		if f, ok := s.byPath[path]; ok {
+			if err := setValue(f.value, fileValue(data[k])); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", file, path, err))
+				continue
+			}
+			cfg.sources[path] = sourceFile
+			continue
+		}
+		if section, ok := data[k].(map[string]any); ok && s.sections[path] {
+			errs = append(errs, s.applyFile(cfg, file, path, section)...)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s: unbekannter Schlüssel %q", file, path))
+	}
+	return errs
+}
+
+// fileValue bringt einen Wert aus der Datei in die Schreibweise der Umgebungsvariablen: Listen
+// werden kommagetrennt, Zuordnungen als "name=wert;name2=wert2" übergeben.
+func fileValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fileValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = k + "=" + fileValue(v[k])
+		}
+		return strings.Join(entries, ";")
+	}
+	return fmt.Sprint(v)
+}
+
+// setValue setzt einen Wert aus seiner Textdarstellung.
+func setValue(v reflect.Value, raw string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("ungültige Dauer %q: erwartet wird z.B. 30s oder 5m", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("ungültige Zahl %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("ungültiger Wahrheitswert %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("nicht unterstützter Typ %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+	default:
+		return fmt.Errorf("nicht unterstützter Typ %s", v.Type())
+	}
+	return nil
+}
+
+// formatValue ist die Umkehrung von setValue für config print.
+func formatValue(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		return string(text)
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue ersetzt geheime Werte in der Ausgabe von Print.
+const redactedValue = "[REDACTED]"
+
+// Print schreibt die wirksame Konfiguration als YAML nach w, in derselben Struktur wie die
+// Konfigurationsdatei. Jeder gesetzte Wert ist mit seiner Quelle kommentiert (default, file oder
+// der Name der Umgebungsvariable). Mit redacted werden geheime Werte wie CLIENT_SECRET oder
+// DB_DSN durch [REDACTED] ersetzt.
+func (c *Config) Print(w io.Writer, redacted bool) error {
+	s := newSchema(c)
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{c.yamlNode(s.root, redacted)}}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (c *Config) yamlNode(n *node, redacted bool) *yaml.Node {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, child := range n.children {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: child.name}
+		if child.field == nil {
+			mapping.Content = append(mapping.Content, key, c.yamlNode(child, redacted))
+			continue
+		}
+
+		f := child.field
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: formatValue(f.value)}
+		if f.secret && redacted && value.Value != "" {
+			value.Value = redactedValue
+		}
+		if value.Value == "" {
+			value.Style = yaml.DoubleQuotedStyle
+		}
+		if source := c.sources[f.path]; source != "" {
+			value.LineComment = source
+		}
+		mapping.Content = append(mapping.Content, key, value)
+	}
+	return mapping
+}
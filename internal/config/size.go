@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Size ist eine Größe in Byte. In Datei und Umgebung wird sie als Zahl mit optionaler Einheit
+// angegeben, z.B. "16MiB", "500KB" oder "1048576".
+type Size int64
+
+// Einheiten für Size. KB, MB und GB sind dezimal, KiB, MiB und GiB binär.
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"GB", 1000 * 1000 * 1000},
+	{"MB", 1000 * 1000},
+	{"KB", 1000},
+	{"B", 1},
+}
+
+// ParseSize liest eine Größe wie "32MiB".
+func ParseSize(s string) (Size, error) {
+	s = strings.TrimSpace(s)
+	factor := int64(1)
+	number := s
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(strings.ToUpper(s), strings.ToUpper(u.suffix)) {
+			factor = u.factor
+			number = strings.TrimSpace(s[:len(s)-len(u.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("ungültige Größe %q: erwartet wird z.B. 16MiB, 500KB oder 1024", s)
+	}
+	return Size(n * factor), nil
+}
+
+// String gibt die Größe in der größten Einheit aus, die sie exakt darstellt; binäre Einheiten
+// haben Vorrang.
+func (s Size) String() string {
+	for _, u := range sizeUnits[:len(sizeUnits)-1] {
+		if s != 0 && int64(s)%u.factor == 0 {
+			return strconv.FormatInt(int64(s)/u.factor, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(s), 10) + "B"
+}
+
+func (s Size) MarshalText() ([]byte, error) { return []byte(s.String()), nil }
+
+func (s *Size) UnmarshalText(text []byte) error {
+	v, err := ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"email-microservice/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// ErrAPIKeyNotFound wird zurückgegeben, wenn kein gültiger (nicht widerrufener) Key zum Hash existiert.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// lastUsedInterval begrenzt, wie oft last_used_at eines Keys geschrieben wird.
+const lastUsedInterval = "1 minute"
+
+const apiKeyColumns = `id, name, key_prefix, app_tags, created_at, revoked_at, last_used_at`
+
+// AddAPIKey speichert einen neuen API-Key mit dem Hash des Keys und gibt seine ID zurück.
+func (c *Client) AddAPIKey(key models.APIKey, keyHash string) (int64, error) {
+	const query = `
+    INSERT INTO api_keys (name, key_prefix, key_hash, app_tags)
+    VALUES ($1, $2, $3, $4)
+    RETURNING id`
+
+	var id int64
+	if err := c.db.QueryRow(query, key.Name, key.Prefix, keyHash, pq.Array(key.AppTags)).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create api key '%s': %w", key.Name, err)
+	}
+	return id, nil
+}
+
+// GetAPIKeyByHash liest einen nicht widerrufenen API-Key anhand des Hashes.
+func (c *Client) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	row := c.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, keyHash)
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys liest alle API-Keys einschließlich widerrufener, sortiert nach Namen.
+func (c *Client) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := c.db.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read api keys: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey widerruft einen API-Key anhand seines Namens und gibt die Anzahl betroffener Zeilen zurück.
+func (c *Client) RevokeAPIKey(name string) (int64, error) {
+	res, err := c.db.Exec(`UPDATE api_keys SET revoked_at = NOW() WHERE name = $1 AND revoked_at IS NULL`, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke api key '%s': %w", name, err)
+	}
+	return res.RowsAffected()
+}
+
+// TouchAPIKey vermerkt die Nutzung eines Keys. Damit nicht jede Anfrage schreibt, wird
+// last_used_at höchstens einmal pro lastUsedInterval aktualisiert.
+func (c *Client) TouchAPIKey(id int64) error {
+	query := `
+    UPDATE api_keys SET last_used_at = NOW()
+    WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '` + lastUsedInterval + `')`
+	if _, err := c.db.Exec(query, id); err != nil {
+		return fmt.Errorf("failed to update last use of api key %d: %w", id, err)
+	}
+	return nil
+}
+
+// scanAPIKey liest einen API-Key aus einer Zeile mit den Spalten aus apiKeyColumns.
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var k models.APIKey
+	var revokedAt, lastUsedAt sql.NullTime
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.AppTags), &k.CreatedAt, &revokedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		k.RevokedAt = &revokedAt.Time
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	return &k, nil
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// SenderMailbox ist ein Postfach eines Senders zusammen mit dem Mandanten, über dessen
+// Anmeldeinformationen es gelesen wird. TenantID nil bedeutet die Standard-Anmeldeinformationen.
+type SenderMailbox struct {
+	Address  string
+	TenantID *int64
+}
+
+// ListSenderMailboxes liefert die From-Adressen und Postfächer aller konfigurierten Sender ohne Duplikate.
+func (c *Client) ListSenderMailboxes() ([]SenderMailbox, error) {
+	rows, err := c.db.Query(`
+    SELECT email, tenant_id FROM senders
+    UNION
+    SELECT mailbox, tenant_id FROM senders WHERE mailbox <> ''
+    ORDER BY 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sender mailboxes: %w", err)
+	}
+	defer rows.Close()
+
+	var mailboxes []SenderMailbox
+	for rows.Next() {
+		var m SenderMailbox
+		var tenantID sql.NullInt64
+		if err := rows.Scan(&m.Address, &tenantID); err != nil {
+			return nil, fmt.Errorf("failed to read sender mailboxes: %w", err)
+		}
+		if tenantID.Valid {
+			m.TenantID = &tenantID.Int64
+		}
+		mailboxes = append(mailboxes, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sender mailboxes: %w", err)
+	}
+	return mailboxes, nil
+}
+
+// GetDeltaLink liest den zuletzt gespeicherten Graph-Delta-Link eines Postfachs.
+// Ein leerer String bedeutet, dass das Postfach noch nie synchronisiert wurde.
+func (c *Client) GetDeltaLink(mailbox string) (string, error) {
+	var deltaLink string
+	err := c.db.QueryRow(`SELECT delta_link FROM mailbox_sync_state WHERE mailbox = $1`, mailbox).Scan(&deltaLink)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read delta link of mailbox '%s': %w", mailbox, err)
+	}
+	return deltaLink, nil
+}
+
+// SaveDeltaLink speichert den Graph-Delta-Link eines Postfachs für die nächste Abfrage.
+func (c *Client) SaveDeltaLink(mailbox, deltaLink string) error {
+	const query = `
+    INSERT INTO mailbox_sync_state (mailbox, delta_link, updated_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT (mailbox) DO UPDATE SET delta_link = EXCLUDED.delta_link, updated_at = NOW()`
+	if _, err := c.db.Exec(query, mailbox, deltaLink); err != nil {
+		return fmt.Errorf("failed to save delta link of mailbox '%s': %w", mailbox, err)
+	}
+	return nil
+}
+
+// JobRecipients liefert alle Empfänger (To, Cc und Bcc) eines Jobs so, wie sie protokolliert
+// wurden. Gibt ErrJobNotFound zurück, wenn kein Job mit der ID existiert.
+func (c *Client) JobRecipients(jobID int64) ([]string, error) {
+	const query = `SELECT recipients, cc_recipients, bcc_recipients FROM mail_jobs WHERE id = $1`
+
+	var to, cc, bcc []string
+	err := c.db.QueryRow(query, jobID).Scan(pq.Array(&to), pq.Array(&cc), pq.Array(&bcc))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients of mail job %d: %w", jobID, err)
+	}
+	return append(append(to, cc...), bcc...), nil
+}
+
+// RecordBounce vermerkt einen gebouncten Empfänger am Job. Mehrfach gemeldete Empfänger werden nur einmal vermerkt.
+// Gibt ErrJobNotFound zurück, wenn kein Job mit der ID existiert.
+func (c *Client) RecordBounce(jobID int64, address string) error {
+	const query = `
+    UPDATE mail_jobs
+    SET bounced_recipients = CASE
+        WHEN $2 = ANY(COALESCE(bounced_recipients, '{}')) THEN bounced_recipients
+        ELSE array_append(COALESCE(bounced_recipients, '{}'), $2)
+    END
+    WHERE id = $1`
+	res, err := c.db.Exec(query, jobID, address)
+	if err != nil {
+		return fmt.Errorf("failed to record bounce of '%s' for mail job %d: %w", address, jobID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix kennzeichnet verschlüsselte Werte. Werte ohne Präfix gelten als Klartext,
+// damit bestehende Zeilen und Umgebungsvariablen weiter funktionieren.
+const encryptedPrefix = "enc:v1:"
+
+// ErrNoMasterKey wird zurückgegeben, wenn verschlüsselt oder entschlüsselt werden soll, aber
+// kein Master-Key (MASTER_KEY bzw. MASTER_KEY_FILE) konfiguriert ist.
+var ErrNoMasterKey = errors.New("no master key configured (MASTER_KEY or MASTER_KEY_FILE)")
+
+// Keyring verschlüsselt Geheimnisse per Envelope-Verschlüsselung: Jeder Wert erhält einen
+// eigenen zufälligen Datenschlüssel (AES-256-GCM), der mit dem Master-Key verschlüsselt neben
+// dem Chiffrat gespeichert wird. Frühere Master-Keys werden nur noch zum Entschlüsseln
+// verwendet, bis alle Zeilen mit 'admin-tool keys rotate' neu verschlüsselt sind.
+type Keyring struct {
+	current  masterKey
+	previous []masterKey
+}
+
+type masterKey struct {
+	id  string
+	key []byte
+}
+
+// NewKeyring erstellt einen Keyring aus Base64-kodierten 32-Byte-Keys.
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	k := &Keyring{}
+	var err error
+	if k.current, err = parseMasterKey(current); err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	for i, p := range previous {
+		mk, err := parseMasterKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous master key %d: %w", i+1, err)
+		}
+		k.previous = append(k.previous, mk)
+	}
+	return k, nil
+}
+
+// GenerateMasterKey erzeugt einen neuen zufälligen Master-Key in Base64-Kodierung.
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// IsEncrypted gibt an, ob ein Wert verschlüsselt gespeichert ist.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Encrypt verschlüsselt einen Wert mit einem neuen Datenschlüssel und dem aktuellen Master-Key.
+// Das Ergebnis hat die Form enc:v1:<key-id>:<verschlüsselter Datenschlüssel>:<Chiffrat>.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	wrappedKey, err := seal(k.current.key, dek)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + k.current.id + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt entschlüsselt einen mit Encrypt erzeugten Wert. Klartextwerte ohne Präfix werden
+// unverändert zurückgegeben. Decrypt darf auf einem nil-Keyring aufgerufen werden.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	mk, ok := k.key(parts[0])
+	if !ok {
+		return "", fmt.Errorf("unknown master key id '%s'", parts[0])
+	}
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	dek, err := open(mk, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	plaintext, err := open(dek, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation gibt an, ob ein Wert im Klartext oder mit einem früheren Master-Key verschlüsselt ist.
+func (k *Keyring) NeedsRotation(value string) bool {
+	return value != "" && !strings.HasPrefix(value, encryptedPrefix+k.current.id+":")
+}
+
+// key sucht einen Master-Key anhand seiner ID.
+func (k *Keyring) key(id string) ([]byte, bool) {
+	if k.current.id == id {
+		return k.current.key, true
+	}
+	for _, p := range k.previous {
+		if p.id == id {
+			return p.key, true
+		}
+	}
+	return nil, false
+}
+
+// parseMasterKey dekodiert einen Base64-Key; die ID sind die ersten 8 Hex-Zeichen seines SHA-256.
+func parseMasterKey(encoded string) (masterKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return masterKey{}, err
+	}
+	if len(key) != 32 {
+		return masterKey{}, fmt.Errorf("expected 32 bytes, got %d", len(key))
+	}
+	sum := sha256.Sum256(key)
+	return masterKey{id: hex.EncodeToString(sum[:4]), key: key}, nil
+}
+
+// seal verschlüsselt data mit AES-256-GCM; die Nonce wird dem Chiffrat vorangestellt.
+func seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// open entschlüsselt ein mit seal erzeugtes Chiffrat.
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,31 +1,14 @@
 package db
 
-import (
-	"errors"
-	"os"
-)
-
-// Config speichert die reine Datenbank-Konfiguration.
-// Die Werte werden aus Umgebungsvariablen geladen.
+// Config speichert die reine Datenbank-Konfiguration. Sie wird von config.Load aus Datei und
+// Umgebungsvariablen gelesen.
 type Config struct {
-	Driver string
-	DSN    string // Data Source Name (Connection-String)
-}
-
-// Load lädt die DB-Konfiguration aus den Umgebungsvariablen.
-func Load() (*Config, error) {
-	cfg := &Config{
-		Driver: os.Getenv("DB_DRIVER"),
-		DSN:    os.Getenv("DB_DSN"),
-	}
-
-	if cfg.Driver == "" {
-		return nil, errors.New("umgebungsvariable DB_DRIVER ist nicht gesetzt")
-	}
-
-	if cfg.DSN == "" {
-		return nil, errors.New("umgebungsvariable DB_DSN ist nicht gesetzt")
-	}
-
-	return cfg, nil
+	Driver string `config:"driver"`
+	DSN    string `config:"dsn" secret:"true"` // Data Source Name (Connection-String)
+	// MasterKey ist der aktuelle Master-Key (32 Byte, Base64), MasterKeyPrevious frühere Keys,
+	// mit denen noch verschlüsselte Werte gelesen werden können.
+	MasterKey         string   `config:"master_key" env:"MASTER_KEY" secret:"true"`
+	MasterKeyPrevious []string `config:"master_key_previous" env:"MASTER_KEY_PREVIOUS" secret:"true"`
+	// Keyring ver- und entschlüsselt Geheimnisse; nil, wenn kein Master-Key konfiguriert ist.
+	Keyring *Keyring `config:"-"`
 }
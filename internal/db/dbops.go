@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
@@ -11,7 +12,8 @@ import (
 
 // Client handles database operations.
 type Client struct {
-	db *sql.DB
+	db      *sql.DB
+	keyring *Keyring
 }
 
 // NewClient initializes a new database client.
@@ -26,6 +28,16 @@ func NewClient(driverName, dataSourceName string) (*Client, error) {
 	return &Client{db: db}, nil
 }
 
+// Ping checks that the database is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+// SetKeyring sets the keyring used to encrypt secrets before they are written.
+func (c *Client) SetKeyring(k *Keyring) {
+	c.keyring = k
+}
+
 // Create inserts a single record into a table based on a struct.
 // KORRIGIERT: Ignoriert jetzt das 'id'-Feld, damit die DB es automatisch generiert.
 func (c *Client) Create(tableName string, model interface{}) (int64, error) {
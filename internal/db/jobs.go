@@ -0,0 +1,224 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"email-microservice/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// Statuswerte eines Eintrags in der Tabelle 'mail_jobs'.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusSending   = "sending"
+	JobStatusSent      = "sent"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+	// JobStatusExpanded kennzeichnet Mail-Merge-Jobs, die in Einzeljobs aufgeteilt wurden.
+	JobStatusExpanded = "expanded"
+	// JobStatusSuppressed kennzeichnet Jobs, deren Empfänger alle auf der Suppression-Liste stehen.
+	JobStatusSuppressed = "suppressed"
+)
+
+var (
+	// ErrJobNotFound wird zurückgegeben, wenn kein Job mit der angegebenen ID existiert.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotCancellable wird zurückgegeben, wenn sich der Job nicht mehr im Status 'queued' befindet.
+	ErrJobNotCancellable = errors.New("job can no longer be cancelled")
+)
+
+// CreateJob protokolliert einen neu angenommenen Job mit dem Status 'queued' und gibt dessen ID zurück.
+func (c *Client) CreateJob(job *models.EmailJob) (int64, error) {
+	const query = `
+    INSERT INTO mail_jobs (recipients, cc_recipients, bcc_recipients, subject, body_content, html_body_content, app_tag, status)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING id`
+
+	// Bei Mail-Merge-Jobs werden die Adressen aller Empfänger protokolliert.
+	recipients := job.Recipients
+	for _, r := range job.MergeRecipients {
+		recipients = append(recipients, r.Address)
+	}
+
+	var id int64
+	err := c.db.QueryRow(query,
+		pq.Array(recipients),
+		pq.Array(job.CcRecipients),
+		pq.Array(job.BccRecipients),
+		job.Subject,
+		job.BodyContent,
+		job.HtmlBodyContent,
+		job.AppTag,
+		JobStatusQueued,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create mail job: %w", err)
+	}
+	return id, nil
+}
+
+// CreateChildJob protokolliert den Einzeljob eines Mail-Merge-Jobs für den Empfänger mit dem
+// angegebenen Index. Existiert der Einzeljob bereits (wiederholter Fan-out), wird seine ID
+// zurückgegeben, ohne ihn erneut anzulegen.
+func (c *Client) CreateChildJob(parentID int64, index int, job *models.EmailJob, status, errorMessage string) (int64, error) {
+	const query = `
+    INSERT INTO mail_jobs (parent_id, merge_index, recipients, subject, body_content, html_body_content, app_tag, status, error_message)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''))
+    ON CONFLICT (parent_id, merge_index) DO UPDATE SET parent_id = EXCLUDED.parent_id
+    RETURNING id`
+
+	var id int64
+	err := c.db.QueryRow(query,
+		parentID,
+		index,
+		pq.Array(job.Recipients),
+		job.Subject,
+		job.BodyContent,
+		job.HtmlBodyContent,
+		job.AppTag,
+		status,
+		errorMessage,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create child job %d of mail job %d: %w", index, parentID, err)
+	}
+	return id, nil
+}
+
+// GetJob liest den Status eines Jobs. Bei Mail-Merge-Jobs wird der Status aller Einzeljobs mitgeliefert.
+func (c *Client) GetJob(id int64) (*models.JobInfo, error) {
+	rows, err := c.db.Query(jobInfoQuery+` WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mail job %d: %w", id, err)
+	}
+	jobs, err := scanJobInfos(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mail job %d: %w", id, err)
+	}
+	if len(jobs) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	rows, err = c.db.Query(jobInfoQuery+` WHERE parent_id = $1 ORDER BY merge_index`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read child jobs of mail job %d: %w", id, err)
+	}
+	jobs[0].Children, err = scanJobInfos(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read child jobs of mail job %d: %w", id, err)
+	}
+	return &jobs[0], nil
+}
+
+const jobInfoQuery = `
+    SELECT id, COALESCE(parent_id, 0), COALESCE(app_tag, ''), recipients, COALESCE(status, ''),
+           COALESCE(error_message, ''), suppressed_recipients, bounced_recipients, created_at, processed_at
+    FROM mail_jobs`
+
+// scanJobInfos liest alle Zeilen einer jobInfoQuery und schließt rows.
+func scanJobInfos(rows *sql.Rows) ([]models.JobInfo, error) {
+	defer rows.Close()
+
+	var jobs []models.JobInfo
+	for rows.Next() {
+		var job models.JobInfo
+		var processedAt sql.NullTime
+		if err := rows.Scan(&job.ID, &job.ParentID, &job.AppTag, pq.Array(&job.Recipients), &job.Status,
+			&job.ErrorMessage, pq.Array(&job.SuppressedRecipients), pq.Array(&job.BouncedRecipients), &job.CreatedAt, &processedAt); err != nil {
+			return nil, err
+		}
+		if processedAt.Valid {
+			job.ProcessedAt = &processedAt.Time
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJob markiert einen Job als 'sending', bevor der Worker ihn versendet.
+// Jobs im Status 'sending' dürfen erneut beansprucht werden, damit eine nach einem
+// Worker-Absturz erneut zugestellte Nachricht nicht verloren geht.
+// Gibt false und den aktuellen Status zurück, wenn der Job nicht (mehr) versendet werden darf.
+func (c *Client) ClaimJob(id int64) (bool, string, error) {
+	const query = `
+    UPDATE mail_jobs SET status = $2
+    WHERE id = $1 AND status IN ($3, $2)
+    RETURNING status`
+
+	var status string
+	err := c.db.QueryRow(query, id, JobStatusSending, JobStatusQueued).Scan(&status)
+	if err == nil {
+		return true, status, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, "", fmt.Errorf("failed to claim mail job %d: %w", id, err)
+	}
+
+	status, err = c.jobStatus(id)
+	if err != nil {
+		return false, "", err
+	}
+	return false, status, nil
+}
+
+// ReleaseJob setzt einen beanspruchten Job zurück auf 'queued', z.B. wenn er nach
+// fehlgeschlagenen Versuchen erneut in die Queue gestellt wird.
+func (c *Client) ReleaseJob(id int64) error {
+	const query = `UPDATE mail_jobs SET status = $2 WHERE id = $1 AND status = $3`
+	if _, err := c.db.Exec(query, id, JobStatusQueued, JobStatusSending); err != nil {
+		return fmt.Errorf("failed to release mail job %d: %w", id, err)
+	}
+	return nil
+}
+
+// FinishJob setzt den Endstatus eines Jobs und protokolliert ggf. eine Fehlermeldung.
+func (c *Client) FinishJob(id int64, status, errorMessage string) error {
+	const query = `
+    UPDATE mail_jobs SET status = $2, error_message = NULLIF($3, ''), processed_at = NOW()
+    WHERE id = $1`
+	if _, err := c.db.Exec(query, id, status, errorMessage); err != nil {
+		return fmt.Errorf("failed to finish mail job %d: %w", id, err)
+	}
+	return nil
+}
+
+// CancelJob storniert einen noch nicht versendeten Job.
+// Befindet sich der Job bereits in Bearbeitung oder ist abgeschlossen, wird
+// ErrJobNotCancellable zusammen mit dem aktuellen Status zurückgegeben.
+func (c *Client) CancelJob(id int64) (string, error) {
+	const query = `
+    UPDATE mail_jobs SET status = $2, processed_at = NOW()
+    WHERE id = $1 AND status = $3
+    RETURNING status`
+
+	var status string
+	err := c.db.QueryRow(query, id, JobStatusCancelled, JobStatusQueued).Scan(&status)
+	if err == nil {
+		return status, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to cancel mail job %d: %w", id, err)
+	}
+
+	status, err = c.jobStatus(id)
+	if err != nil {
+		return "", err
+	}
+	return status, ErrJobNotCancellable
+}
+
+// jobStatus liest den aktuellen Status eines Jobs.
+func (c *Client) jobStatus(id int64) (string, error) {
+	var status sql.NullString
+	err := c.db.QueryRow(`SELECT status FROM mail_jobs WHERE id = $1`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrJobNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read status of mail job %d: %w", id, err)
+	}
+	return status.String, nil
+}
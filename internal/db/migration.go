@@ -2,8 +2,9 @@ package db
 
 import (
 	"database/sql"
+	"email-microservice/internal/logging"
 	"fmt"
-	"log"
+	"strings"
 )
 
 // Migrate führt die Datenbankmigrationen aus, um sicherzustellen,
@@ -22,12 +23,31 @@ func (c *Client) Migrate() error {
 	if _, err := c.db.Exec(createSendersTableSQL); err != nil {
 		return fmt.Errorf("fehler beim Erstellen der 'senders'-Tabelle: %w", err)
 	}
-	log.Println("Tabelle 'senders' ist bereit.")
+	logging.Infof("Tabelle 'senders' ist bereit.")
 
 	// Ein Index auf 'app_tag' beschleunigt die Suche erheblich.
 	const createIndexSQL = `CREATE UNIQUE INDEX IF NOT EXISTS idx_senders_app_tag ON senders(app_tag);`
 	if _, err := c.db.Exec(createIndexSQL); err != nil {
-		log.Printf("Warnung: Fehler beim Erstellen des Index für 'senders': %v", err)
+		logging.Warnf("Warnung: Fehler beim Erstellen des Index für 'senders': %v", err)
+	}
+
+	// Standardwerte für Nachrichteneigenschaften pro App-Tag.
+	const alterSendersDefaultsSQL = `
+    ALTER TABLE senders
+        ADD COLUMN IF NOT EXISTS reply_to VARCHAR(255) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS importance VARCHAR(10) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS save_to_sent_items BOOLEAN NOT NULL DEFAULT TRUE;`
+	if _, err := c.db.Exec(alterSendersDefaultsSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'senders'-Tabelle um Standardwerte: %w", err)
+	}
+
+	// Postfach für die Graph-URL und Sender-Adresse, getrennt von der From-Adresse ('email').
+	const alterSendersSendAsSQL = `
+    ALTER TABLE senders
+        ADD COLUMN IF NOT EXISTS mailbox VARCHAR(255) NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS sender_address VARCHAR(255) NOT NULL DEFAULT '';`
+	if _, err := c.db.Exec(alterSendersSendAsSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'senders'-Tabelle um Postfach und Sender-Adresse: %w", err)
 	}
 
 	// 2. Tabelle zum Protokollieren von E-Mail-Aufträgen erstellen.
@@ -50,9 +70,155 @@ func (c *Client) Migrate() error {
 	if _, err := c.db.Exec(createMailJobsTableSQL); err != nil {
 		return fmt.Errorf("fehler beim Erstellen der 'mail_jobs'-Tabelle: %w", err)
 	}
-	log.Println("Tabelle 'mail_jobs' ist bereit.")
+	logging.Infof("Tabelle 'mail_jobs' ist bereit.")
+
+	// 3. Spalten für Mail-Merge: Einzeljobs verweisen auf ihren Eltern-Job.
+	const alterMailJobsMergeSQL = `
+    ALTER TABLE mail_jobs
+        ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES mail_jobs(id),
+        ADD COLUMN IF NOT EXISTS merge_index INTEGER;`
+
+	if _, err := c.db.Exec(alterMailJobsMergeSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'mail_jobs'-Tabelle um Mail-Merge-Spalten: %w", err)
+	}
+
+	// Der eindeutige Index verhindert doppelte Einzeljobs, wenn ein Fan-out wiederholt wird.
+	const createMergeIndexSQL = `CREATE UNIQUE INDEX IF NOT EXISTS idx_mail_jobs_parent_merge ON mail_jobs(parent_id, merge_index);`
+	if _, err := c.db.Exec(createMergeIndexSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen des Index für Mail-Merge-Jobs: %w", err)
+	}
+
+	// 4. Tabelle für erlaubte und gesperrte Empfänger pro App-Tag erstellen.
+	const createRecipientPoliciesTableSQL = `
+    CREATE TABLE IF NOT EXISTS recipient_policies (
+        id SERIAL PRIMARY KEY,
+        app_tag VARCHAR(50) NOT NULL,
+        rule_type VARCHAR(10) NOT NULL CHECK (rule_type IN ('allow', 'block')),
+        pattern VARCHAR(255) NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        UNIQUE (app_tag, rule_type, pattern)
+    );`
+
+	if _, err := c.db.Exec(createRecipientPoliciesTableSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen der 'recipient_policies'-Tabelle: %w", err)
+	}
+	logging.Infof("Tabelle 'recipient_policies' ist bereit.")
+
+	// 5. Globale Suppression-Liste für gebouncte und abgemeldete Adressen erstellen.
+	const createSuppressionsTableSQL = `
+    CREATE TABLE IF NOT EXISTS suppressions (
+        id SERIAL PRIMARY KEY,
+        address VARCHAR(255) NOT NULL UNIQUE,
+        reason VARCHAR(50) NOT NULL,
+        source VARCHAR(50) NOT NULL,
+        expires_at TIMESTAMPTZ,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );`
+
+	if _, err := c.db.Exec(createSuppressionsTableSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen der 'suppressions'-Tabelle: %w", err)
+	}
+	logging.Infof("Tabelle 'suppressions' ist bereit.")
+
+	// Am Job wird vermerkt, welche Empfänger wegen der Suppression-Liste entfernt wurden.
+	const alterMailJobsSuppressedSQL = `ALTER TABLE mail_jobs ADD COLUMN IF NOT EXISTS suppressed_recipients TEXT[];`
+	if _, err := c.db.Exec(alterMailJobsSuppressedSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'mail_jobs'-Tabelle um 'suppressed_recipients': %w", err)
+	}
+
+	// 6. Synchronisationsstand der Postfächer für die Auswertung von Unzustellbarkeitsberichten.
+	const createMailboxSyncStateTableSQL = `
+    CREATE TABLE IF NOT EXISTS mailbox_sync_state (
+        mailbox VARCHAR(255) PRIMARY KEY,
+        delta_link TEXT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );`
+
+	if _, err := c.db.Exec(createMailboxSyncStateTableSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen der 'mailbox_sync_state'-Tabelle: %w", err)
+	}
+	logging.Infof("Tabelle 'mailbox_sync_state' ist bereit.")
+
+	// Am Job wird vermerkt, welche Empfänger laut Unzustellbarkeitsbericht gebounct sind.
+	const alterMailJobsBouncedSQL = `ALTER TABLE mail_jobs ADD COLUMN IF NOT EXISTS bounced_recipients TEXT[];`
+	if _, err := c.db.Exec(alterMailJobsBouncedSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'mail_jobs'-Tabelle um 'bounced_recipients': %w", err)
+	}
+
+	// 7. Mandanten (Microsoft-365-Tenants) mit eigenen App-Anmeldeinformationen.
+	const createTenantsTableSQL = `
+    CREATE TABLE IF NOT EXISTS tenants (
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(100) NOT NULL UNIQUE,
+        azure_tenant_id VARCHAR(100) NOT NULL,
+        client_id VARCHAR(100) NOT NULL,
+        client_secret TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );`
+
+	if _, err := c.db.Exec(createTenantsTableSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen der 'tenants'-Tabelle: %w", err)
+	}
+	logging.Infof("Tabelle 'tenants' ist bereit.")
+
+	// Sender ohne Mandant verwenden die Anmeldeinformationen aus der Umgebung.
+	const alterSendersTenantSQL = `ALTER TABLE senders ADD COLUMN IF NOT EXISTS tenant_id INTEGER REFERENCES tenants(id);`
+	if _, err := c.db.Exec(alterSendersTenantSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'senders'-Tabelle um 'tenant_id': %w", err)
+	}
+
+	// 8. Anmeldung per Zertifikat: Verweise auf PEM-Dateien, das Client-Secret wird dann nicht benötigt.
+	const alterTenantsCertificateSQL = `
+    ALTER TABLE tenants
+        ALTER COLUMN client_secret SET DEFAULT '',
+        ADD COLUMN IF NOT EXISTS certificate_path TEXT NOT NULL DEFAULT '',
+        ADD COLUMN IF NOT EXISTS private_key_path TEXT NOT NULL DEFAULT '';`
+	if _, err := c.db.Exec(alterTenantsCertificateSQL); err != nil {
+		return fmt.Errorf("fehler beim Erweitern der 'tenants'-Tabelle um Zertifikatsverweise: %w", err)
+	}
+
+	// 9. API-Keys der Clients; gespeichert wird nur der SHA-256-Hash des Keys.
+	const createAPIKeysTableSQL = `
+    CREATE TABLE IF NOT EXISTS api_keys (
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(100) NOT NULL UNIQUE,
+        key_prefix VARCHAR(20) NOT NULL,
+        key_hash CHAR(64) NOT NULL UNIQUE,
+        app_tags TEXT[] NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        revoked_at TIMESTAMPTZ,
+        last_used_at TIMESTAMPTZ
+    );`
+
+	if _, err := c.db.Exec(createAPIKeysTableSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen der 'api_keys'-Tabelle: %w", err)
+	}
+	logging.Infof("Tabelle 'api_keys' ist bereit.")
+
+	// 10. Änderungen an Sendern, Mandanten und Empfänger-Regeln per NOTIFY melden, damit der
+	// Worker seinen Cache ohne Neustart aktualisiert. Die Nutzlast ist der Tabellenname.
+	const createNotifyFunctionSQL = `
+    CREATE OR REPLACE FUNCTION notify_config_changed() RETURNS trigger AS $$
+    BEGIN
+        PERFORM pg_notify('` + ConfigChangedChannel + `', TG_TABLE_NAME);
+        RETURN NULL;
+    END;
+    $$ LANGUAGE plpgsql;`
+	if _, err := c.db.Exec(createNotifyFunctionSQL); err != nil {
+		return fmt.Errorf("fehler beim Erstellen der Funktion 'notify_config_changed': %w", err)
+	}
+	for _, table := range ConfigTables {
+		triggerSQL := fmt.Sprintf(`
+    DROP TRIGGER IF EXISTS config_changed ON %[1]s;
+    CREATE TRIGGER config_changed AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON %[1]s
+        FOR EACH STATEMENT EXECUTE FUNCTION notify_config_changed();`, table)
+		if _, err := c.db.Exec(triggerSQL); err != nil {
+			return fmt.Errorf("fehler beim Erstellen des Triggers für '%s': %w", table, err)
+		}
+	}
+	logging.Infof("Änderungsbenachrichtigungen für %s sind eingerichtet.", strings.Join(ConfigTables, ", "))
 
-	log.Println("Datenbankmigration erfolgreich überprüft/abgeschlossen.")
+	logging.Infof("Datenbankmigration erfolgreich überprüft/abgeschlossen.")
 	return nil
 }
 
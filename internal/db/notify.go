@@ -0,0 +1,15 @@
+package db
+
+// ConfigChangedChannel ist der NOTIFY-Kanal, auf dem Trigger Änderungen an den Tabellen aus
+// ConfigTables melden. Die Nutzlast ist der Name der geänderten Tabelle.
+const ConfigChangedChannel = "config_changed"
+
+// Tabellen, deren Änderungen über ConfigChangedChannel gemeldet werden.
+const (
+	SendersTable  = "senders"
+	TenantsTable  = "tenants"
+	PoliciesTable = "recipient_policies"
+)
+
+// ConfigTables sind die Tabellen mit Konfiguration, die der Worker im Speicher hält.
+var ConfigTables = []string{SendersTable, TenantsTable, PoliciesTable}
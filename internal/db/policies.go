@@ -0,0 +1,61 @@
+package db
+
+import (
+	"fmt"
+
+	"email-microservice/internal/models"
+)
+
+// ListPolicyRules liest die Empfänger-Regeln eines App-Tags. Ein leerer App-Tag liefert alle Regeln.
+func (c *Client) ListPolicyRules(appTag string) ([]models.PolicyRule, error) {
+	query := `SELECT id, app_tag, rule_type, pattern FROM recipient_policies`
+	var args []interface{}
+	if appTag != "" {
+		query += ` WHERE app_tag = $1`
+		args = append(args, appTag)
+	}
+	query += ` ORDER BY app_tag, rule_type, pattern`
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.PolicyRule
+	for rows.Next() {
+		var r models.PolicyRule
+		if err := rows.Scan(&r.ID, &r.AppTag, &r.RuleType, &r.Pattern); err != nil {
+			return nil, fmt.Errorf("failed to read policy rules: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read policy rules: %w", err)
+	}
+	return rules, nil
+}
+
+// AddPolicyRule legt eine Empfänger-Regel an. Existiert sie bereits, wird die bestehende ID zurückgegeben.
+func (c *Client) AddPolicyRule(rule models.PolicyRule) (int64, error) {
+	const query = `
+    INSERT INTO recipient_policies (app_tag, rule_type, pattern)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (app_tag, rule_type, pattern) DO UPDATE SET pattern = EXCLUDED.pattern
+    RETURNING id`
+
+	var id int64
+	if err := c.db.QueryRow(query, rule.AppTag, rule.RuleType, rule.Pattern).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create policy rule: %w", err)
+	}
+	return id, nil
+}
+
+// DeletePolicyRule löscht eine Empfänger-Regel anhand ihrer ID und gibt die Anzahl gelöschter Zeilen zurück.
+func (c *Client) DeletePolicyRule(id int64) (int64, error) {
+	res, err := c.db.Exec(`DELETE FROM recipient_policies WHERE id = $1`, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete policy rule %d: %w", id, err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"email-microservice/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// AddSuppression setzt eine Adresse auf die Suppression-Liste. Ein bestehender Eintrag für
+// dieselbe Adresse wird mit Grund, Quelle und Ablaufdatum überschrieben.
+func (c *Client) AddSuppression(s models.Suppression) (int64, error) {
+	const query = `
+    INSERT INTO suppressions (address, reason, source, expires_at)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (address) DO UPDATE
+        SET reason = EXCLUDED.reason, source = EXCLUDED.source, expires_at = EXCLUDED.expires_at, created_at = NOW()
+    RETURNING id`
+
+	var id int64
+	if err := c.db.QueryRow(query, s.Address, s.Reason, s.Source, s.ExpiresAt).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to add suppression for '%s': %w", s.Address, err)
+	}
+	return id, nil
+}
+
+// ImportSuppressions fügt viele Einträge in einer Transaktion hinzu und gibt deren Anzahl zurück.
+func (c *Client) ImportSuppressions(entries []models.Suppression) (int64, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin suppression import: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+    INSERT INTO suppressions (address, reason, source, expires_at)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (address) DO UPDATE
+        SET reason = EXCLUDED.reason, source = EXCLUDED.source, expires_at = EXCLUDED.expires_at, created_at = NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare suppression import: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, s := range entries {
+		if _, err := stmt.Exec(s.Address, s.Reason, s.Source, s.ExpiresAt); err != nil {
+			return 0, fmt.Errorf("failed to import suppression for '%s': %w", s.Address, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit suppression import: %w", err)
+	}
+	return int64(len(entries)), nil
+}
+
+// RemoveSuppression entfernt eine Adresse von der Suppression-Liste.
+func (c *Client) RemoveSuppression(address string) (int64, error) {
+	res, err := c.db.Exec(`DELETE FROM suppressions WHERE address = $1`, address)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove suppression for '%s': %w", address, err)
+	}
+	return res.RowsAffected()
+}
+
+// ListSuppressions liest alle Einträge der Suppression-Liste, abgelaufene nur auf Wunsch.
+func (c *Client) ListSuppressions(includeExpired bool) ([]models.Suppression, error) {
+	query := suppressionQuery
+	if !includeExpired {
+		query += ` WHERE expires_at IS NULL OR expires_at > NOW()`
+	}
+	query += ` ORDER BY address`
+
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppressions: %w", err)
+	}
+	return scanSuppressions(rows)
+}
+
+// ActiveSuppressions liefert die nicht abgelaufenen Einträge für die angegebenen Adressen,
+// indiziert nach Adresse. Die Adressen müssen in normalisierter Form (address.Key) vorliegen.
+func (c *Client) ActiveSuppressions(addresses []string) (map[string]models.Suppression, error) {
+	result := make(map[string]models.Suppression)
+	if len(addresses) == 0 {
+		return result, nil
+	}
+
+	rows, err := c.db.Query(suppressionQuery+` WHERE address = ANY($1) AND (expires_at IS NULL OR expires_at > NOW())`, pq.Array(addresses))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppressions: %w", err)
+	}
+	entries, err := scanSuppressions(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range entries {
+		result[s.Address] = s
+	}
+	return result, nil
+}
+
+// RecordSuppressedRecipients vermerkt am Job, welche Empfänger wegen der Suppression-Liste entfernt wurden.
+func (c *Client) RecordSuppressedRecipients(jobID int64, addresses []string) error {
+	const query = `UPDATE mail_jobs SET suppressed_recipients = $2 WHERE id = $1`
+	if _, err := c.db.Exec(query, jobID, pq.Array(addresses)); err != nil {
+		return fmt.Errorf("failed to record suppressed recipients of mail job %d: %w", jobID, err)
+	}
+	return nil
+}
+
+const suppressionQuery = `SELECT id, address, reason, source, expires_at, created_at FROM suppressions`
+
+// scanSuppressions liest alle Zeilen einer suppressionQuery und schließt rows.
+func scanSuppressions(rows *sql.Rows) ([]models.Suppression, error) {
+	defer rows.Close()
+
+	var entries []models.Suppression
+	for rows.Next() {
+		var s models.Suppression
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.Address, &s.Reason, &s.Source, &expiresAt, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to read suppressions: %w", err)
+		}
+		if expiresAt.Valid {
+			s.ExpiresAt = &expiresAt.Time
+		}
+		entries = append(entries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read suppressions: %w", err)
+	}
+	return entries, nil
+}
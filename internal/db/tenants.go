@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"email-microservice/internal/models"
+)
+
+// ErrTenantNotFound wird zurückgegeben, wenn kein Mandant mit der angegebenen ID oder dem Namen existiert.
+var ErrTenantNotFound = errors.New("tenant not found")
+
+const tenantColumns = `id, name, azure_tenant_id, client_id, client_secret, certificate_path, private_key_path, created_at`
+
+// AddTenant legt einen Mandanten an. Existiert der Name bereits, werden seine Anmeldeinformationen
+// aktualisiert. Das Client-Secret wird mit dem Keyring verschlüsselt gespeichert.
+func (c *Client) AddTenant(t models.Tenant) (int64, error) {
+	if t.ClientSecret != "" {
+		encrypted, err := c.keyring.Encrypt(t.ClientSecret)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt client secret of tenant '%s': %w", t.Name, err)
+		}
+		t.ClientSecret = encrypted
+	}
+
+	const query = `
+    INSERT INTO tenants (name, azure_tenant_id, client_id, client_secret, certificate_path, private_key_path)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (name) DO UPDATE SET
+        azure_tenant_id = EXCLUDED.azure_tenant_id,
+        client_id = EXCLUDED.client_id,
+        client_secret = EXCLUDED.client_secret,
+        certificate_path = EXCLUDED.certificate_path,
+        private_key_path = EXCLUDED.private_key_path
+    RETURNING id`
+
+	var id int64
+	if err := c.db.QueryRow(query, t.Name, t.AzureTenantID, t.ClientID, t.ClientSecret, t.CertificatePath, t.PrivateKeyPath).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create tenant '%s': %w", t.Name, err)
+	}
+	return id, nil
+}
+
+// GetTenant liest einen Mandanten anhand seiner ID. Das Client-Secret bleibt verschlüsselt; es
+// wird erst im Graph-Client entschlüsselt.
+func (c *Client) GetTenant(id int64) (*models.Tenant, error) {
+	t, err := scanTenant(c.db.QueryRow(`SELECT `+tenantColumns+` FROM tenants WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrTenantNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant %d: %w", id, err)
+	}
+	return t, nil
+}
+
+// GetTenantByName liest einen Mandanten anhand seines Namens.
+func (c *Client) GetTenantByName(name string) (*models.Tenant, error) {
+	t, err := scanTenant(c.db.QueryRow(`SELECT `+tenantColumns+` FROM tenants WHERE name = $1`, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: '%s'", ErrTenantNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant '%s': %w", name, err)
+	}
+	return t, nil
+}
+
+// ListTenants liest alle Mandanten sortiert nach Namen.
+func (c *Client) ListTenants() ([]models.Tenant, error) {
+	rows, err := c.db.Query(`SELECT ` + tenantColumns + ` FROM tenants ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tenants: %w", err)
+		}
+		tenants = append(tenants, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// DeleteTenant löscht einen Mandanten anhand seines Namens und gibt die Anzahl gelöschter Zeilen zurück.
+// Solange noch Sender auf den Mandanten verweisen, schlägt das Löschen fehl.
+func (c *Client) DeleteTenant(name string) (int64, error) {
+	res, err := c.db.Exec(`DELETE FROM tenants WHERE name = $1`, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete tenant '%s': %w", name, err)
+	}
+	return res.RowsAffected()
+}
+
+// RotateTenantSecrets verschlüsselt alle Client-Secrets, die im Klartext oder mit einem früheren
+// Master-Key gespeichert sind, in einer Transaktion mit dem aktuellen Master-Key neu.
+// Zurückgegeben wird die Anzahl der neu verschlüsselten Zeilen.
+func (c *Client) RotateTenantSecrets() (int, error) {
+	if c.keyring == nil {
+		return 0, ErrNoMasterKey
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, client_secret FROM tenants ORDER BY id FOR UPDATE`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read tenant secrets: %w", err)
+	}
+	secrets := make(map[int64]string)
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read tenant secrets: %w", err)
+		}
+		if c.keyring.NeedsRotation(secret) {
+			secrets[id] = secret
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read tenant secrets: %w", err)
+	}
+
+	for _, id := range ids {
+		plaintext, err := c.keyring.Decrypt(secrets[id])
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt client secret of tenant %d: %w", id, err)
+		}
+		encrypted, err := c.keyring.Encrypt(plaintext)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt client secret of tenant %d: %w", id, err)
+		}
+		if _, err := tx.Exec(`UPDATE tenants SET client_secret = $1 WHERE id = $2`, encrypted, id); err != nil {
+			return 0, fmt.Errorf("failed to update client secret of tenant %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(ids), nil
+}
+
+// scanTenant liest einen Mandanten aus einer Zeile mit den Spalten aus tenantColumns.
+func scanTenant(row interface{ Scan(...interface{}) error }) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := row.Scan(&t.ID, &t.Name, &t.AzureTenantID, &t.ClientID, &t.ClientSecret, &t.CertificatePath, &t.PrivateKeyPath, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
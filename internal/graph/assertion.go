@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// clientAssertionType ist der Wert für client_assertion_type beim Zertifikats-Flow (RFC 7523).
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// assertionLifetime ist die Gültigkeitsdauer einer signierten Client-Assertion.
+const assertionLifetime = 10 * time.Minute
+
+// certificate ist ein geladenes Zertifikat mit dem zugehörigen privaten RSA-Schlüssel.
+type certificate struct {
+	der []byte
+	key *rsa.PrivateKey
+}
+
+// loadCertificate liest ein PEM-Zertifikat und den zugehörigen privaten Schlüssel (PKCS#1 oder
+// PKCS#8). Zertifikat und Schlüssel dürfen auch in derselben Datei stehen.
+func loadCertificate(certPath, keyPath string) (*certificate, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	cert := &certificate{}
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert.der = block.Bytes
+			break
+		}
+	}
+	if cert.der == nil {
+		return nil, fmt.Errorf("no PEM certificate found in %s", certPath)
+	}
+	parsed, err := x509.ParseCertificate(cert.der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	for block, rest := pem.Decode(keyPEM); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			cert.key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			var key interface{}
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err == nil {
+				var ok bool
+				if cert.key, ok = key.(*rsa.PrivateKey); !ok {
+					return nil, errors.New("private key is not an RSA key")
+				}
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		break
+	}
+	if cert.key == nil {
+		return nil, fmt.Errorf("no PEM private key found in %s", keyPath)
+	}
+
+	pub, ok := parsed.PublicKey.(*rsa.PublicKey)
+	if !ok || !pub.Equal(&cert.key.PublicKey) {
+		return nil, errors.New("private key does not match the certificate")
+	}
+	return cert, nil
+}
+
+// clientAssertion erstellt eine mit RS256 signierte JWT-Client-Assertion für den Token-Endpunkt
+// tokenURL. Der Header enthält die Fingerabdrücke x5t (SHA-1) und x5t#S256 des Zertifikats,
+// anhand derer Azure AD das registrierte Zertifikat auswählt.
+func (c *certificate) clientAssertion(clientID, tokenURL string, now time.Time) (string, error) {
+	sha1Sum := sha1.Sum(c.der)
+	sha256Sum := sha256.Sum256(c.der)
+	header := map[string]string{
+		"alg":      "RS256",
+		"typ":      "JWT",
+		"x5t":      base64.RawURLEncoding.EncodeToString(sha1Sum[:]),
+		"x5t#S256": base64.RawURLEncoding.EncodeToString(sha256Sum[:]),
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate assertion id: %w", err)
+	}
+	claims := map[string]interface{}{
+		"aud": tokenURL,
+		"iss": clientID,
+		"sub": clientID,
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(assertionLifetime).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign client assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,160 @@
+package graph
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate erzeugt ein selbstsigniertes Zertifikat und schreibt Zertifikat und
+// PKCS#8-Schlüssel als PEM-Dateien in ein temporäres Verzeichnis.
+func testCertificate(t *testing.T) (certPath, keyPath string, der []byte, key *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "email-microservice-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err = x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "PRIVATE KEY", pkcs8)
+	return certPath, keyPath, der, key
+}
+
+func writePEM(t *testing.T, path, blockType string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decodeJWT zerlegt ein JWT in Header, Claims, Signatur-Eingabe und Signatur.
+func decodeJWT(t *testing.T, token string) (header, claims map[string]interface{}, signingInput string, signature []byte) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token has %d parts, want 3", len(parts))
+	}
+	for i, v := range []*map[string]interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatalf("decode segment %d: %v", i, err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("unmarshal segment %d: %v", i, err)
+		}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("decode signature: %v", err)
+	}
+	return header, claims, parts[0] + "." + parts[1], signature
+}
+
+func TestClientAssertion(t *testing.T) {
+	certPath, keyPath, der, key := testCertificate(t)
+	cert, err := loadCertificate(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("loadCertificate: %v", err)
+	}
+
+	const tokenURL = "https://login.example.com/tenant/oauth2/v2.0/token"
+	now := time.Unix(1700000000, 0)
+	assertion, err := cert.clientAssertion("client-id", tokenURL, now)
+	if err != nil {
+		t.Fatalf("clientAssertion: %v", err)
+	}
+	header, claims, signingInput, signature := decodeJWT(t, assertion)
+
+	sha1Sum := sha1.Sum(der)
+	sha256Sum := sha256.Sum256(der)
+	wantHeader := map[string]string{
+		"alg":      "RS256",
+		"typ":      "JWT",
+		"x5t":      base64.RawURLEncoding.EncodeToString(sha1Sum[:]),
+		"x5t#S256": base64.RawURLEncoding.EncodeToString(sha256Sum[:]),
+	}
+	for name, want := range wantHeader {
+		if got := header[name]; got != want {
+			t.Errorf("header %s = %v, want %v", name, got, want)
+		}
+	}
+
+	wantClaims := map[string]interface{}{
+		"aud": tokenURL,
+		"iss": "client-id",
+		"sub": "client-id",
+		"iat": float64(now.Unix()),
+		"nbf": float64(now.Unix()),
+		"exp": float64(now.Add(assertionLifetime).Unix()),
+	}
+	for name, want := range wantClaims {
+		if got := claims[name]; got != want {
+			t.Errorf("claim %s = %v, want %v", name, got, want)
+		}
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		t.Error("claim jti is empty")
+	}
+
+	digest := sha256.Sum256([]byte(signingInput))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature does not verify with the certificate key: %v", err)
+	}
+}
+
+func TestLoadCertificateRejectsMismatchedKey(t *testing.T) {
+	certPath, _, _, _ := testCertificate(t)
+	_, otherKeyPath, _, _ := testCertificate(t)
+
+	_, err := loadCertificate(certPath, otherKeyPath)
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("loadCertificate with foreign key: err = %v, want mismatch error", err)
+	}
+}
+
+func TestLoadCertificatePKCS1InSameFile(t *testing.T) {
+	certPath, _, der, key := testCertificate(t)
+	combined := filepath.Join(t.TempDir(), "combined.pem")
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+	if err := os.WriteFile(combined, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadCertificate(combined, combined); err != nil {
+		t.Fatalf("loadCertificate(combined): %v", err)
+	}
+	if _, err := loadCertificate(certPath, certPath); err == nil {
+		t.Fatal("loadCertificate without private key: err = nil, want error")
+	}
+}
@@ -2,20 +2,61 @@ package graph
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"email-microservice/internal/config"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/metrics"
+	"email-microservice/internal/telemetry"
 )
 
+// graphBaseURL ist die Basis-URL der Microsoft Graph API (v1.0).
+const graphBaseURL = "https://graph.microsoft.com/v1.0"
+
+// tokenExpiryLeeway ist die Zeitspanne vor Ablauf, ab der ein zwischengespeichertes Token erneuert wird.
+const tokenExpiryLeeway = time.Minute
+
+// defaultAuthorityHost ist der Token-Aussteller, falls AZURE_AUTHORITY_HOST nicht gesetzt ist.
+const defaultAuthorityHost = "https://login.microsoftonline.com"
+
+// Credentials sind die Anmeldeinformationen einer Azure-AD-App in einem Tenant. Sind
+// CertificatePath und PrivateKeyPath gesetzt, meldet sich die App mit einer per Zertifikat
+// signierten Client-Assertion statt mit dem ClientSecret an.
+type Credentials struct {
+	TenantID        string
+	ClientID        string
+	ClientSecret    string
+	CertificatePath string
+	PrivateKeyPath  string
+}
+
+// usesCertificate gibt an, ob die Anmeldung per Zertifikat erfolgt.
+func (c Credentials) usesCertificate() bool {
+	return c.CertificatePath != "" && c.PrivateKeyPath != ""
+}
+
+// Client sendet über die Graph API eines Tenants. Jeder Client hat einen eigenen Token-Cache.
 type Client struct {
 	cfg    *config.Config
+	creds  Credentials
 	client *http.Client
+	// defaultMailbox ist das Postfach für Nachrichten ohne MessageOptions.Mailbox. Nur der
+	// Standard-Client hat eines (SENDER_EMAIL); es gehört zum Tenant aus der Konfiguration.
+	defaultMailbox string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
 }
 
 // Attachment definiert die Struktur für Anhänge, wie sie vom Worker übergeben werden.
@@ -25,22 +66,86 @@ type Attachment struct {
 	MimeType string
 }
 
+// Recipient ist ein Empfänger mit optionalem Anzeigenamen (Graph: emailAddress.name).
+type Recipient struct {
+	Address string
+	Name    string
+}
+
+// Importance-Werte einer Nachricht.
+const (
+	ImportanceLow    = "low"
+	ImportanceNormal = "normal"
+	ImportanceHigh   = "high"
+)
+
+// ValidImportance prüft, ob der Wert für Importance zulässig ist. Leer bedeutet Graph-Standard (normal).
+func ValidImportance(importance string) bool {
+	switch importance {
+	case "", ImportanceLow, ImportanceNormal, ImportanceHigh:
+		return true
+	}
+	return false
+}
+
+// MessageOptions enthält optionale Nachrichteneigenschaften von Graph.
+//
+// Mailbox ist das Postfach, über das versendet wird (/users/{mailbox}/sendMail); leer bedeutet
+// beim Standard-Client SENDER_EMAIL aus der Konfiguration, bei Mandanten-Clients ist es
+// Pflicht. From und Sender setzen message.from und message.sender, z.B. für den Versand als
+// oder im Auftrag eines freigegebenen Postfachs. Dafür benötigt das Postfach die Berechtigung
+// "Senden als" bzw. "Senden im Auftrag von".
+type MessageOptions struct {
+	Mailbox                    string
+	From                       *Recipient
+	Sender                     *Recipient
+	ReplyTo                    []Recipient
+	Importance                 string
+	IsReadReceiptRequested     bool
+	IsDeliveryReceiptRequested bool
+	Categories                 []string
+	SaveToSentItems            bool
+}
+
 // oAuthTokenResponse wird verwendet, um die Antwort des Token-Endpunkts zu parsen.
 type oAuthTokenResponse struct {
 	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// oAuthErrorResponse ist die Fehlerantwort des Token-Endpunkts.
+type oAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
 }
 
 // emailMessage ist die Hauptstruktur für die an die Graph-API gesendete JSON-Payload.
 type emailMessage struct {
-	Message struct {
-		Subject       string       `json:"subject"`
-		Body          body         `json:"body"`
-		ToRecipients  []recipient  `json:"toRecipients"`
-		CcRecipients  []recipient  `json:"ccRecipients,omitempty"`
-		BccRecipients []recipient  `json:"bccRecipients,omitempty"`
-		Attachments   []attachment `json:"attachments,omitempty"`
-	} `json:"message"`
-	SaveToSentItems bool `json:"saveToSentItems"`
+	Message         message `json:"message"`
+	SaveToSentItems bool    `json:"saveToSentItems"`
+}
+
+type message struct {
+	Subject                    string                  `json:"subject"`
+	Body                       body                    `json:"body"`
+	ToRecipients               []recipient             `json:"toRecipients"`
+	CcRecipients               []recipient             `json:"ccRecipients,omitempty"`
+	BccRecipients              []recipient             `json:"bccRecipients,omitempty"`
+	Attachments                []attachment            `json:"attachments,omitempty"`
+	InternetMessageHeaders     []internetMessageHeader `json:"internetMessageHeaders,omitempty"`
+	ReplyTo                    []recipient             `json:"replyTo,omitempty"`
+	Importance                 string                  `json:"importance,omitempty"`
+	IsReadReceiptRequested     bool                    `json:"isReadReceiptRequested,omitempty"`
+	IsDeliveryReceiptRequested bool                    `json:"isDeliveryReceiptRequested,omitempty"`
+	Categories                 []string                `json:"categories,omitempty"`
+	From                       *recipient              `json:"from,omitempty"`
+	Sender                     *recipient              `json:"sender,omitempty"`
+}
+
+// internetMessageHeader ist ein benutzerdefinierter Header (Graph erlaubt nur Namen mit "X-").
+type internetMessageHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 type body struct {
@@ -54,6 +159,7 @@ type recipient struct {
 
 type emailAddress struct {
 	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
 }
 
 // attachment ist die interne Struktur für Anhänge im Graph-API-Format.
@@ -64,39 +170,74 @@ type attachment struct {
 	ContentBytes string `json:"contentBytes"`
 }
 
-// NewClient erstellt eine neue Instanz des Graph-API-Clients.
+// NewClient erstellt eine neue Instanz des Graph-API-Clients mit den Anmeldeinformationen aus der Konfiguration.
 func NewClient(cfg *config.Config) *Client {
-	log.Printf("[DEBUG] Graph-Client wird initialisiert mit TenantID: %s, ClientID: %s", cfg.TenantID, cfg.ClientID)
+	c := NewTenantClient(cfg, Credentials{
+		TenantID:        cfg.TenantID,
+		ClientID:        cfg.ClientID,
+		ClientSecret:    cfg.ClientSecret,
+		CertificatePath: cfg.ClientCertPath,
+		PrivateKeyPath:  cfg.ClientKeyPath,
+	})
+	c.defaultMailbox = cfg.SenderEmail
+	return c
+}
+
+// NewTenantClient erstellt einen Graph-API-Client mit eigenen Anmeldeinformationen, z.B. für
+// einen weiteren Tenant. Er hat kein Standard-Postfach: SENDER_EMAIL gehört zum Tenant aus der
+// Konfiguration, daher muss jede Nachricht MessageOptions.Mailbox setzen.
+func NewTenantClient(cfg *config.Config, creds Credentials) *Client {
 	return &Client{
 		cfg:    cfg,
-		client: &http.Client{Timeout: 20 * time.Second},
+		creds:  creds,
+		client: &http.Client{Timeout: 20 * time.Second, Transport: telemetry.Transport(nil)},
 	}
 }
 
-// SendEmail wurde angepasst. Die UserID wurde entfernt, der Absender wird aus der Konfiguration (cfg.SenderEmail) bezogen.
+// SendEmail wurde angepasst. Die UserID wurde entfernt, das Postfach wird aus opts.Mailbox bzw. beim Standard-Client aus der Konfiguration (cfg.SenderEmail) bezogen.
 func (c *Client) SendEmail(
-	recipients, ccRecipients, bccRecipients []string,
+	ctx context.Context,
+	recipients, ccRecipients, bccRecipients []Recipient,
 	subject, bodyContent, contentType string,
-	attachments []Attachment) (*http.Response, error) {
+	attachments []Attachment,
+	headers map[string]string,
+	opts MessageOptions) (*http.Response, error) {
 
-	accessToken, err := c.getAccessToken()
+	if err := validateHeaders(headers); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeaders, err)
+	}
+	if !ValidImportance(opts.Importance) {
+		return nil, fmt.Errorf("invalid importance %q", opts.Importance)
+	}
+
+	// GEÄNDERT: Die URL wird mit dem Postfach des Senders bzw. der SENDER_EMAIL aus der Konfiguration erstellt.
+	mailbox := opts.Mailbox
+	if mailbox == "" {
+		mailbox = c.defaultMailbox
+	}
+	if mailbox == "" {
+		return nil, ErrNoMailbox
+	}
+
+	accessToken, err := c.getAccessToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
+	graphAPIURL := fmt.Sprintf("%s/users/%s/sendMail", graphBaseURL, url.PathEscape(mailbox))
+	logging.Debugf("Sending email via Graph API endpoint: %s", graphAPIURL)
 
-	// GEÄNDERT: Die URL wird jetzt mit der SENDER_EMAIL aus der Konfiguration erstellt.
-	graphAPIURL := fmt.Sprintf("https://graph.microsoft.com/v1.0/users/%s/sendMail", c.cfg.SenderEmail)
-	log.Printf("[DEBUG] Sending email via Graph API endpoint: %s", graphAPIURL)
-
-	var toRecipients, ccRecipientsPayload, bccRecipientsPayload []recipient
-	for _, email := range recipients {
-		toRecipients = append(toRecipients, recipient{EmailAddress: emailAddress{Address: email}})
+	var toRecipients, ccRecipientsPayload, bccRecipientsPayload, replyToPayload []recipient
+	for _, r := range opts.ReplyTo {
+		replyToPayload = append(replyToPayload, recipient{EmailAddress: emailAddress{Address: r.Address, Name: r.Name}})
+	}
+	for _, r := range recipients {
+		toRecipients = append(toRecipients, recipient{EmailAddress: emailAddress{Address: r.Address, Name: r.Name}})
 	}
-	for _, email := range ccRecipients {
-		ccRecipientsPayload = append(ccRecipientsPayload, recipient{EmailAddress: emailAddress{Address: email}})
+	for _, r := range ccRecipients {
+		ccRecipientsPayload = append(ccRecipientsPayload, recipient{EmailAddress: emailAddress{Address: r.Address, Name: r.Name}})
 	}
-	for _, email := range bccRecipients {
-		bccRecipientsPayload = append(bccRecipientsPayload, recipient{EmailAddress: emailAddress{Address: email}})
+	for _, r := range bccRecipients {
+		bccRecipientsPayload = append(bccRecipientsPayload, recipient{EmailAddress: emailAddress{Address: r.Address, Name: r.Name}})
 	}
 
 	var graphAttachments []attachment
@@ -110,23 +251,37 @@ func (c *Client) SendEmail(
 		})
 	}
 
+	var fromPayload, senderPayload *recipient
+	if opts.From != nil {
+		fromPayload = &recipient{EmailAddress: emailAddress{Address: opts.From.Address, Name: opts.From.Name}}
+	}
+	if opts.Sender != nil {
+		senderPayload = &recipient{EmailAddress: emailAddress{Address: opts.Sender.Address, Name: opts.Sender.Name}}
+	}
+
+	var messageHeaders []internetMessageHeader
+	for _, name := range sortedKeys(headers) {
+		messageHeaders = append(messageHeaders, internetMessageHeader{Name: name, Value: headers[name]})
+	}
+
 	email := emailMessage{
-		Message: struct {
-			Subject       string       `json:"subject"`
-			Body          body         `json:"body"`
-			ToRecipients  []recipient  `json:"toRecipients"`
-			CcRecipients  []recipient  `json:"ccRecipients,omitempty"`
-			BccRecipients []recipient  `json:"bccRecipients,omitempty"`
-			Attachments   []attachment `json:"attachments,omitempty"`
-		}{
-			Subject:       subject,
-			Body:          body{ContentType: contentType, Content: bodyContent},
-			ToRecipients:  toRecipients,
-			CcRecipients:  ccRecipientsPayload,
-			BccRecipients: bccRecipientsPayload,
-			Attachments:   graphAttachments,
+		Message: message{
+			Subject:                    subject,
+			Body:                       body{ContentType: contentType, Content: bodyContent},
+			ToRecipients:               toRecipients,
+			CcRecipients:               ccRecipientsPayload,
+			BccRecipients:              bccRecipientsPayload,
+			Attachments:                graphAttachments,
+			InternetMessageHeaders:     messageHeaders,
+			ReplyTo:                    replyToPayload,
+			Importance:                 opts.Importance,
+			IsReadReceiptRequested:     opts.IsReadReceiptRequested,
+			IsDeliveryReceiptRequested: opts.IsDeliveryReceiptRequested,
+			Categories:                 opts.Categories,
+			From:                       fromPayload,
+			Sender:                     senderPayload,
 		},
-		SaveToSentItems: true,
+		SaveToSentItems: opts.SaveToSentItems,
 	}
 
 	emailBytes, err := json.Marshal(email)
@@ -134,42 +289,110 @@ func (c *Client) SendEmail(
 		return nil, fmt.Errorf("failed to marshal email message: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", graphAPIURL, bytes.NewBuffer(emailBytes))
+	ctx, span := telemetry.StartSpan(ctx, "graph.send_mail", "graph.mailbox", mailbox)
+	req, err := http.NewRequestWithContext(ctx, "POST", graphAPIURL, bytes.NewBuffer(emailBytes))
 	if err != nil {
+		span.End(err)
 		return nil, fmt.Errorf("failed to create email request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	return c.client.Do(req)
+	resp, err := c.do("send_mail", req)
+	if resp != nil {
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+	}
+	span.End(err)
+	return resp, err
+}
+
+// do führt eine Anfrage aus und erfasst ihre Dauer unter der angegebenen Operation.
+func (c *Client) do(operation string, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	metrics.GraphRequestDuration.WithLabelValues(operation, metrics.StatusLabel(resp, err)).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// Authenticate stellt sicher, dass ein gültiges Zugriffstoken vorliegt, und fordert bei Bedarf
+// ein neues an. Für Readiness-Prüfungen.
+func (c *Client) Authenticate(ctx context.Context) error {
+	_, err := c.getAccessToken(ctx)
+	return err
 }
 
-// getAccessToken ruft ein OAuth2-Zugriffstoken von Microsoft Identity Platform ab.
-func (c *Client) getAccessToken() (string, error) {
-	log.Printf("[DEBUG] Versuche Token abzurufen mit TenantID: [%s] und ClientID: [%s]", c.cfg.TenantID, c.cfg.ClientID)
-	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", c.cfg.TenantID)
+// getAccessToken liefert ein OAuth2-Zugriffstoken von Microsoft Identity Platform. Das Token wird
+// bis kurz vor seinem Ablauf zwischengespeichert.
+func (c *Client) getAccessToken(ctx context.Context) (token string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+	ctx, span := telemetry.StartSpan(ctx, "graph.token", "auth.certificate", c.creds.usesCertificate())
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		metrics.TokenRefreshes.WithLabelValues(result).Inc()
+		span.End(err)
+	}()
+
+	logging.Debugf("Zugriffstoken abgelaufen oder nicht vorhanden, fordere ein neues an")
+	authorityHost := c.cfg.AuthorityHost
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authorityHost, "/"), url.PathEscape(c.creds.TenantID))
+	form := url.Values{
+		"client_id":  {c.creds.ClientID},
+		"scope":      {"https://graph.microsoft.com/.default"},
+		"grant_type": {"client_credentials"},
+	}
+	if c.creds.usesCertificate() {
+		cert, err := loadCertificate(c.creds.CertificatePath, c.creds.PrivateKeyPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		assertion, err := cert.clientAssertion(c.creds.ClientID, tokenURL, time.Now())
+		if err != nil {
+			return "", err
+		}
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	} else {
+		// Das Secret kann verschlüsselt vorliegen und wird nur hier im Speicher entschlüsselt.
+		secret, err := c.cfg.DB.Keyring.Decrypt(c.creds.ClientSecret)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt client secret: %w", err)
+		}
+		form.Set("client_secret", secret)
+	}
 	data := new(bytes.Buffer)
-	_, err := data.WriteString(fmt.Sprintf("client_id=%s&scope=https://graph.microsoft.com/.default&client_secret=%s&grant_type=client_credentials",
-		c.cfg.ClientID, c.cfg.ClientSecret))
+	_, err = data.WriteString(form.Encode())
 	if err != nil {
 		return "", fmt.Errorf("failed to create request body: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", tokenURL, data)
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, data)
 	if err != nil {
 		return "", fmt.Errorf("failed to create token request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do("token", req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send token request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get token, status: %d, response: %s", resp.StatusCode, string(bodyBytes))
+		// Nur Fehlercode und Beschreibung übernehmen, nicht die vollständige Antwort.
+		var tokenErr oAuthErrorResponse
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&tokenErr)
+		return "", fmt.Errorf("failed to get token, status: %d, error: %s: %s", resp.StatusCode, tokenErr.Error, firstLine(tokenErr.ErrorDescription))
 	}
 
 	var tokenResponse oAuthTokenResponse
@@ -177,5 +400,25 @@ func (c *Client) getAccessToken() (string, error) {
 		return "", fmt.Errorf("failed to decode token response: %w", err)
 	}
 
-	return tokenResponse.AccessToken, nil
+	c.accessToken = tokenResponse.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(tokenResponse.ExpiresIn)*time.Second - tokenExpiryLeeway)
+	return c.accessToken, nil
+}
+
+// firstLine liefert die erste Zeile eines Textes; Azure AD hängt Trace- und Korrelations-IDs in weiteren Zeilen an.
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// sortedKeys liefert die Schlüssel einer Map sortiert, damit die Payload deterministisch ist.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
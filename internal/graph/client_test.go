@@ -0,0 +1,181 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"email-microservice/internal/config"
+	"email-microservice/internal/db"
+)
+
+// fakeTokenEndpoint ist ein lokaler Token-Endpunkt, der die Formulare der Token-Anfragen
+// aufzeichnet und ein festes Token ausgibt.
+type fakeTokenEndpoint struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	paths    []string
+	requests []url.Values
+}
+
+func newFakeTokenEndpoint(t *testing.T) *fakeTokenEndpoint {
+	t.Helper()
+	f := &fakeTokenEndpoint{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		f.mu.Lock()
+		f.paths = append(f.paths, r.URL.Path)
+		f.requests = append(f.requests, r.PostForm)
+		f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(oAuthTokenResponse{AccessToken: "test-token", ExpiresIn: 3600})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeTokenEndpoint) form(t *testing.T) url.Values {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) != 1 {
+		t.Fatalf("token endpoint received %d requests, want 1", len(f.requests))
+	}
+	if want := "/tenant-id/oauth2/v2.0/token"; f.paths[0] != want {
+		t.Errorf("token request path = %q, want %q", f.paths[0], want)
+	}
+	return f.requests[0]
+}
+
+func testClient(endpoint *fakeTokenEndpoint, creds Credentials, keyring *db.Keyring) *Client {
+	cfg := &config.Config{AuthorityHost: endpoint.URL + "/"}
+	cfg.DB.Keyring = keyring
+	creds.TenantID = "tenant-id"
+	creds.ClientID = "client-id"
+	return NewTenantClient(cfg, creds)
+}
+
+func TestGetAccessTokenWithSecret(t *testing.T) {
+	endpoint := newFakeTokenEndpoint(t)
+	c := testClient(endpoint, Credentials{ClientSecret: "plain-secret"}, nil)
+
+	token, err := c.getAccessToken(context.Background())
+	if err != nil {
+		t.Fatalf("getAccessToken: %v", err)
+	}
+	if token != "test-token" {
+		t.Errorf("token = %q, want test-token", token)
+	}
+
+	form := endpoint.form(t)
+	want := map[string]string{
+		"grant_type":    "client_credentials",
+		"client_id":     "client-id",
+		"scope":         "https://graph.microsoft.com/.default",
+		"client_secret": "plain-secret",
+	}
+	for name, value := range want {
+		if got := form.Get(name); got != value {
+			t.Errorf("form %s = %q, want %q", name, got, value)
+		}
+	}
+	for _, name := range []string{"client_assertion", "client_assertion_type"} {
+		if form.Has(name) {
+			t.Errorf("secret flow sent %s", name)
+		}
+	}
+}
+
+func TestGetAccessTokenDecryptsSecret(t *testing.T) {
+	masterKey, err := db.GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := db.NewKeyring(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := keyring.Encrypt("plain-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint := newFakeTokenEndpoint(t)
+	c := testClient(endpoint, Credentials{ClientSecret: encrypted}, keyring)
+	if _, err := c.getAccessToken(context.Background()); err != nil {
+		t.Fatalf("getAccessToken: %v", err)
+	}
+	if got := endpoint.form(t).Get("client_secret"); got != "plain-secret" {
+		t.Errorf("client_secret = %q, want the decrypted secret", got)
+	}
+}
+
+func TestGetAccessTokenWithCertificate(t *testing.T) {
+	certPath, keyPath, _, _ := testCertificate(t)
+	endpoint := newFakeTokenEndpoint(t)
+	// Sind Zertifikat und Secret gesetzt, hat das Zertifikat Vorrang.
+	c := testClient(endpoint, Credentials{ClientSecret: "plain-secret", CertificatePath: certPath, PrivateKeyPath: keyPath}, nil)
+
+	if _, err := c.getAccessToken(context.Background()); err != nil {
+		t.Fatalf("getAccessToken: %v", err)
+	}
+
+	form := endpoint.form(t)
+	if form.Has("client_secret") {
+		t.Error("certificate flow sent client_secret")
+	}
+	if got := form.Get("client_assertion_type"); got != clientAssertionType {
+		t.Errorf("client_assertion_type = %q, want %q", got, clientAssertionType)
+	}
+	header, claims, _, _ := decodeJWT(t, form.Get("client_assertion"))
+	for _, name := range []string{"x5t", "x5t#S256"} {
+		if v, _ := header[name].(string); v == "" {
+			t.Errorf("client_assertion header has no %s", name)
+		}
+	}
+	if want := endpoint.URL + "/tenant-id/oauth2/v2.0/token"; claims["aud"] != want {
+		t.Errorf("client_assertion aud = %v, want %q", claims["aud"], want)
+	}
+}
+
+func TestGetAccessTokenIsCached(t *testing.T) {
+	endpoint := newFakeTokenEndpoint(t)
+	c := testClient(endpoint, Credentials{ClientSecret: "plain-secret"}, nil)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.getAccessToken(context.Background()); err != nil {
+			t.Fatalf("getAccessToken #%d: %v", i+1, err)
+		}
+	}
+	endpoint.form(t)
+}
+
+func TestGetAccessTokenError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(oAuthErrorResponse{
+			Error:            "invalid_client",
+			ErrorDescription: "AADSTS7000215: Invalid client secret provided.\r\nTrace ID: 123",
+		})
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{AuthorityHost: srv.URL}
+	c := NewTenantClient(cfg, Credentials{TenantID: "tenant-id", ClientID: "client-id", ClientSecret: "wrong"})
+	_, err := c.getAccessToken(context.Background())
+	if err == nil {
+		t.Fatal("getAccessToken: err = nil, want error")
+	}
+	if !strings.Contains(err.Error(), "invalid_client: AADSTS7000215") || strings.Contains(err.Error(), "Trace ID") {
+		t.Errorf("error = %q, want code and first line of the description only", err)
+	}
+}
@@ -0,0 +1,74 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Fehlercodes der Graph-API, die gesondert behandelt werden.
+const (
+	// CodeSendAsDenied: Der App fehlt das Recht, als bzw. im Auftrag der From-Adresse zu senden.
+	CodeSendAsDenied = "ErrorSendAsDenied"
+	// CodeAccessDenied: Der App fehlt z.B. die Berechtigung Mail.Send für das Postfach.
+	CodeAccessDenied = "ErrorAccessDenied"
+)
+
+// APIError ist eine Fehlerantwort der Graph-API.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// RequestID ist der Header request-id der Antwort, den der Microsoft-Support zur Zuordnung benötigt.
+	RequestID string
+	// Body ist die unveränderte Antwort, falls sie kein Graph-Fehlerobjekt enthält.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("graph API returned status %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("graph API returned status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// PermissionDenied meldet, ob der Versand an fehlenden Send-As-, Send-on-Behalf- oder
+// Mail.Send-Berechtigungen gescheitert ist. Ein erneuter Versuch ist dann zwecklos.
+func (e *APIError) PermissionDenied() bool {
+	return e.StatusCode == http.StatusForbidden && (e.Code == CodeSendAsDenied || e.Code == CodeAccessDenied)
+}
+
+// maxErrorBodySize begrenzt, wie viel einer Fehlerantwort gelesen wird.
+const maxErrorBodySize = 64 << 10
+
+// Transient meldet, ob ein erneuter Versuch sinnvoll sein kann: bei Drosselung (429),
+// Server-Fehlern und fehlender Authentifizierung oder Berechtigung (401, 403).
+func (e *APIError) Transient() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= 500:
+		return true
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return true
+	}
+	return false
+}
+
+// maxErrorBodySize liest die Fehlerantwort der Graph-API aus resp. Vom Body werden höchstens
+// maxErrorBodySize Bytes gelesen.
+func ReadError(resp *http.Response) *APIError {
+	bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("request-id"), Body: string(bodyBytes)}
+
+	var payload struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(bodyBytes, &payload) == nil {
+		apiErr.Code = payload.Error.Code
+		apiErr.Message = payload.Error.Message
+	}
+	return apiErr
+}
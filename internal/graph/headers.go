@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidHeaders wird von SendEmail zurückgegeben, wenn die Header abgelehnt wurden.
+// Der Fehler ist dauerhaft; ein erneuter Versuch ist zwecklos.
+var ErrInvalidHeaders = errors.New("invalid internet message headers")
+
+// ErrNoMailbox wird von SendEmail zurückgegeben, wenn ein Mandanten-Client ohne Postfach
+// senden soll. SENDER_EMAIL gehört zum Tenant aus der Konfiguration und wird dafür nicht verwendet.
+var ErrNoMailbox = errors.New("no mailbox configured for tenant client")
+
+const (
+	// HeaderJobID ist der Header, über den Unzustellbarkeitsberichte (NDRs) einem Job zugeordnet werden.
+	HeaderJobID = "X-Job-ID"
+	// HeaderAppTag enthält den App-Tag des versendenden Systems.
+	HeaderAppTag = "X-App-Tag"
+
+	// MaxCustomHeaders begrenzt die Anzahl benutzerdefinierter Header pro Nachricht.
+	MaxCustomHeaders = 10
+	// maxHeaderValueLength ist die maximale Länge eines Header-Werts (RFC 5322 Zeilenlänge).
+	maxHeaderValueLength = 998
+)
+
+// reservedHeaders werden vom Worker gesetzt und dürfen nicht vom Aufrufer überschrieben werden.
+var reservedHeaders = []string{HeaderJobID, HeaderAppTag}
+
+// ValidateHeader prüft einen benutzerdefinierten Header. Graph akzeptiert in
+// internetMessageHeaders nur Namen, die mit "X-" beginnen.
+func ValidateHeader(name, value string) error {
+	if len(name) <= 2 || !strings.EqualFold(name[:2], "x-") {
+		return fmt.Errorf("header name %q must start with 'X-'", name)
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || r == ':' {
+			return fmt.Errorf("header name %q contains invalid characters", name)
+		}
+	}
+	for _, reserved := range reservedHeaders {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("header %q is set by the mail service and must not be provided", name)
+		}
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("header %q must not contain line breaks", name)
+	}
+	if len(value) > maxHeaderValueLength {
+		return fmt.Errorf("header %q must not be longer than %d characters", name, maxHeaderValueLength)
+	}
+	return nil
+}
+
+// validateHeaders prüft alle Header einer Nachricht. Die vom Worker gesetzten Header sind erlaubt.
+func validateHeaders(headers map[string]string) error {
+	if len(headers) > MaxCustomHeaders+len(reservedHeaders) {
+		return fmt.Errorf("too many internet message headers: %d", len(headers))
+	}
+	for name, value := range headers {
+		if isReserved(name) {
+			continue
+		}
+		if err := ValidateHeader(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isReserved(name string) bool {
+	for _, reserved := range reservedHeaders {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
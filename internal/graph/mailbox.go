@@ -0,0 +1,132 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxMessageSize begrenzt die Größe einer per MessageMIME geladenen Nachricht.
+// Unzustellbarkeitsberichte sind klein; größere Nachrichten werden nicht geladen.
+const maxMessageSize = 4 << 20
+
+// ErrMessageTooLarge wird von MessageMIME zurückgegeben, wenn die Nachricht größer als
+// maxMessageSize ist.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MessageSummary ist die Kurzform einer Nachricht aus einer Delta-Abfrage.
+type MessageSummary struct {
+	ID          string
+	Subject     string
+	FromAddress string
+	// Removed ist gesetzt, wenn die Nachricht seit der letzten Abfrage gelöscht oder verschoben wurde.
+	Removed bool
+}
+
+// deltaResponse ist eine Seite der Antwort auf eine Delta-Abfrage.
+type deltaResponse struct {
+	Value []struct {
+		ID      string `json:"id"`
+		Subject string `json:"subject"`
+		From    *struct {
+			EmailAddress emailAddress `json:"emailAddress"`
+		} `json:"from"`
+		Removed *struct {
+			Reason string `json:"reason"`
+		} `json:"@removed"`
+	} `json:"value"`
+	NextLink  string `json:"@odata.nextLink"`
+	DeltaLink string `json:"@odata.deltaLink"`
+}
+
+// InboxDelta liest alle Nachrichten des Posteingangs, die sich seit deltaLink geändert haben.
+// Ohne deltaLink beginnt eine vollständige Synchronisation. Zurückgegeben wird der neue
+// Delta-Link für die nächste Abfrage.
+func (c *Client) InboxDelta(mailbox, deltaLink string) ([]MessageSummary, string, error) {
+	next := deltaLink
+	if next == "" {
+		next = fmt.Sprintf("%s/users/%s/mailFolders/inbox/messages/delta?$select=subject,from", graphBaseURL, url.PathEscape(mailbox))
+	}
+
+	var messages []MessageSummary
+	for next != "" {
+		resp, err := c.get(next, map[string]string{"Prefer": "odata.maxpagesize=50"})
+		if err != nil {
+			return nil, "", err
+		}
+
+		var page deltaResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode delta response: %w", err)
+		}
+
+		for _, v := range page.Value {
+			m := MessageSummary{ID: v.ID, Subject: v.Subject, Removed: v.Removed != nil}
+			if v.From != nil {
+				m.FromAddress = v.From.EmailAddress.Address
+			}
+			messages = append(messages, m)
+		}
+
+		if page.DeltaLink != "" {
+			return messages, page.DeltaLink, nil
+		}
+		next = page.NextLink
+	}
+	return nil, "", fmt.Errorf("delta response for mailbox '%s' contained neither nextLink nor deltaLink", mailbox)
+}
+
+// MessageMIME lädt eine Nachricht im MIME-Format (RFC 822). Nachrichten über maxMessageSize
+// werden mit ErrMessageTooLarge abgelehnt.
+func (c *Client) MessageMIME(mailbox, messageID string) ([]byte, error) {
+	mimeURL := fmt.Sprintf("%s/users/%s/messages/%s/$value", graphBaseURL, url.PathEscape(mailbox), url.PathEscape(messageID))
+	resp, err := c.get(mimeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message %s: %w", messageID, err)
+	}
+	if len(content) > maxMessageSize {
+		return nil, fmt.Errorf("message %s exceeds %d bytes: %w", messageID, maxMessageSize, ErrMessageTooLarge)
+	}
+	return content, nil
+}
+
+// get führt einen authentifizierten GET-Aufruf aus. Antworten außer 200 werden als *APIError
+// zurückgegeben.
+func (c *Client) get(requestURL string, headers map[string]string) (*http.Response, error) {
+	accessToken, err := c.getAccessToken(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.do("mailbox", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := ReadError(resp)
+		resp.Body.Close()
+		return nil, apiErr
+	}
+	return resp, nil
+}
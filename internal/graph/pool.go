@@ -0,0 +1,56 @@
+package graph
+
+import (
+	"sync"
+
+	"email-microservice/internal/config"
+	"email-microservice/internal/models"
+)
+
+// Pool verwaltet einen Graph-Client pro Mandant, damit jeder Tenant seinen eigenen Token-Cache hat.
+// Sender ohne Mandant verwenden den Standard-Client mit den Anmeldeinformationen aus der Konfiguration.
+type Pool struct {
+	cfg           *config.Config
+	defaultClient *Client
+
+	mu      sync.Mutex
+	clients map[int64]*Client
+}
+
+// NewPool erstellt einen Pool mit dem Standard-Client aus der Konfiguration.
+func NewPool(cfg *config.Config) *Pool {
+	return &Pool{
+		cfg:           cfg,
+		defaultClient: NewClient(cfg),
+		clients:       make(map[int64]*Client),
+	}
+}
+
+// Default liefert den Client mit den Anmeldeinformationen aus der Konfiguration.
+func (p *Pool) Default() *Client {
+	return p.defaultClient
+}
+
+// Get liefert den Client eines Mandanten; nil liefert den Standard-Client. Haben sich die
+// Anmeldeinformationen des Mandanten geändert, wird ein neuer Client mit leerem Token-Cache erstellt.
+func (p *Pool) Get(tenant *models.Tenant) *Client {
+	if tenant == nil {
+		return p.defaultClient
+	}
+	creds := Credentials{
+		TenantID:        tenant.AzureTenantID,
+		ClientID:        tenant.ClientID,
+		ClientSecret:    tenant.ClientSecret,
+		CertificatePath: tenant.CertificatePath,
+		PrivateKeyPath:  tenant.PrivateKeyPath,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.clients[tenant.ID]; ok && c.creds == creds {
+		return c
+	}
+	c := NewTenantClient(p.cfg, creds)
+	p.clients[tenant.ID] = c
+	return c
+}
@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSConnection prüft, ob die NATS-Verbindung besteht. Während eines Reconnects schlägt die
+// Prüfung ebenfalls fehl; nach Aufgabe aller Reconnect-Versuche bleibt die Verbindung geschlossen.
+func NATSConnection(nc *nats.Conn) CheckFunc {
+	return func(context.Context) error {
+		if status := nc.Status(); status != nats.CONNECTED {
+			return fmt.Errorf("connection state is %s", status)
+		}
+		return nil
+	}
+}
+
+// Stream prüft, ob der JetStream-Stream erreichbar ist.
+func Stream(js nats.JetStreamContext, stream string) CheckFunc {
+	return func(ctx context.Context) error {
+		if _, err := js.StreamInfo(stream, nats.Context(ctx)); err != nil {
+			return fmt.Errorf("stream %s is not available: %w", stream, err)
+		}
+		return nil
+	}
+}
+
+// Pinger ist eine Abhängigkeit, die per Ping geprüft wird, z.B. *db.Client.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping prüft eine Abhängigkeit per Ping.
+func Ping(p Pinger) CheckFunc {
+	return p.Ping
+}
+
+// Recent prüft, ob last() höchstens maxAge zurückliegt, z.B. der letzte erfolgreiche Fetch.
+func Recent(last func() time.Time, maxAge time.Duration) CheckFunc {
+	return func(context.Context) error {
+		t := last()
+		if t.IsZero() {
+			return fmt.Errorf("never succeeded")
+		}
+		if age := time.Since(t); age > maxAge {
+			return fmt.Errorf("last success %s ago (limit %s)", age.Round(time.Second), maxAge)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,125 @@
+// Package health stellt /healthz (Liveness) und /readyz (Readiness) mit Prüfungen der
+// Abhängigkeiten bereit. Beide Endpunkte antworten mit 200 bzw. 503 und einem JSON-Objekt mit
+// dem Ergebnis jeder Prüfung.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout begrenzt die Dauer einer einzelnen Prüfung.
+const checkTimeout = 3 * time.Second
+
+// CheckFunc prüft eine Abhängigkeit; nil bedeutet gesund.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+	// liveness gibt an, ob ein Fehler einen Neustart rechtfertigt und daher auch /healthz betrifft.
+	liveness bool
+}
+
+// Checker sammelt die Prüfungen eines Dienstes.
+type Checker struct {
+	checks []check
+}
+
+// NewChecker erstellt einen Checker ohne Prüfungen.
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddLiveness registriert eine Prüfung für /healthz und /readyz. Sie sollte nur fehlschlagen,
+// wenn sich der Dienst nicht von selbst erholt, z.B. bei endgültig verlorener NATS-Verbindung.
+func (c *Checker) AddLiveness(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn, liveness: true})
+}
+
+// AddReadiness registriert eine Prüfung, die nur /readyz betrifft.
+func (c *Checker) AddReadiness(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Register bindet /healthz und /readyz an den ServeMux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.handler(true))
+	mux.HandleFunc("/readyz", c.handler(false))
+}
+
+// Result ist das Ergebnis einer Prüfung.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report ist die Antwort von /healthz und /readyz.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Werte für Report.Status und Result.Status.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Run führt die Prüfungen parallel aus; mit livenessOnly nur die Liveness-Prüfungen.
+func (c *Checker) Run(ctx context.Context, livenessOnly bool) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		if livenessOnly && !chk.liveness {
+			continue
+		}
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := chk.fn(ctx)
+			res := Result{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status = StatusFail
+				res.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = res
+			if err != nil {
+				report.Status = StatusFail
+			}
+		}(chk)
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) handler(livenessOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Only GET requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report := c.Run(r.Context(), livenessOnly)
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,113 @@
+package jwtauth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config beschreibt, welche JWTs die API akzeptiert und wie daraus App-Tags abgeleitet werden.
+// Die Werte werden von config.Load gelesen; ohne JWKSFile und JWKSURL ist die
+// JWT-Authentifizierung deaktiviert.
+type Config struct {
+	// JWKSFile oder JWKSURL liefern die öffentlichen Schlüssel des Ausstellers.
+	JWKSFile string `config:"jwks_file"`
+	JWKSURL  string `config:"jwks_url"`
+	// JWKSCacheTTL ist die Zeit, nach der die Schlüssel von JWKSURL neu geladen werden.
+	JWKSCacheTTL time.Duration `config:"jwks_cache_ttl" default:"1h"`
+
+	Issuer   string `config:"issuer"`
+	Audience string `config:"audience"`
+	// Leeway toleriert Uhrabweichungen bei exp und nbf.
+	Leeway time.Duration `config:"leeway" default:"1m"`
+
+	// AppTagClaim ist der Claim (z.B. "roles" oder "appid"), aus dem die App-Tags abgeleitet werden.
+	AppTagClaim string `config:"app_tag_claim" default:"roles"`
+	// AppTagPrefix filtert Claim-Werte und entfernt das Präfix, z.B. "mail.send." für Rollen
+	// wie "mail.send.rechnungssystem". Wird nur ohne AppTagMap verwendet.
+	AppTagPrefix string `config:"app_tag_prefix"`
+	// AppTagMap ordnet Claim-Werte (z.B. eine appid) App-Tags zu.
+	AppTagMap AppTagMap `config:"app_tag_map"`
+	// AdminRole ist ein Wert des App-Tag-Claims (z.B. die Rolle "mail.admin"), der die Verwaltung
+	// der Suppression-Liste erlaubt. Ohne AdminRole ist das per JWT nicht möglich.
+	AdminRole string `config:"admin_role"`
+}
+
+// Enabled meldet, ob die JWT-Authentifizierung konfiguriert ist.
+func (c *Config) Enabled() bool {
+	return c.JWKSFile != "" || c.JWKSURL != ""
+}
+
+// Validate prüft eine aktivierte Konfiguration.
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	if c.JWKSFile != "" && c.JWKSURL != "" {
+		errs = append(errs, errors.New("JWT_JWKS_FILE und JWT_JWKS_URL dürfen nicht gemeinsam gesetzt sein"))
+	}
+	if c.Issuer == "" {
+		errs = append(errs, errors.New("JWT_ISSUER ist nicht gesetzt"))
+	}
+	if c.Audience == "" {
+		errs = append(errs, errors.New("JWT_AUDIENCE ist nicht gesetzt"))
+	}
+	if c.AppTagClaim == "" {
+		errs = append(errs, errors.New("JWT_APP_TAG_CLAIM ist nicht gesetzt"))
+	}
+	return errors.Join(errs...)
+}
+
+// AppTagMap ordnet Claim-Werte App-Tags zu. In Datei und Umgebung hat sie das Format
+// "wert=tag1,tag2;wert2=tag3".
+type AppTagMap map[string][]string
+
+func (m *AppTagMap) UnmarshalText(text []byte) error {
+	parsed, err := ParseAppTagMap(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func (m AppTagMap) MarshalText() ([]byte, error) {
+	values := make([]string, 0, len(m))
+	for value := range m {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	entries := make([]string, len(values))
+	for i, value := range values {
+		entries[i] = value + "=" + strings.Join(m[value], ",")
+	}
+	return []byte(strings.Join(entries, ";")), nil
+}
+
+// ParseAppTagMap liest eine Zuordnung im Format "wert=tag1,tag2;wert2=tag3". Ein leerer
+// String ergibt nil.
+func ParseAppTagMap(s string) (AppTagMap, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	m := make(AppTagMap)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		value, tags, ok := strings.Cut(entry, "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("entry %q must have the form value=tag1,tag2", entry)
+		}
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				m[value] = append(m[value], t)
+			}
+		}
+	}
+	return m, nil
+}
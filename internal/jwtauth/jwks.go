@@ -0,0 +1,224 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"email-microservice/internal/logging"
+)
+
+// minRefreshInterval begrenzt, wie oft die JWKS bei unbekannter Key-ID neu geladen werden.
+const minRefreshInterval = time.Minute
+
+// jwk ist ein einzelner Schlüssel einer JWKS (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet lädt die Schlüssel aus einer Datei oder einer URL und hält sie zwischengespeichert.
+type keySet struct {
+	file   string
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	// fetchMu sorgt dafür, dass nur ein Abruf gleichzeitig läuft. Der Abruf selbst findet
+	// außerhalb von mu statt, damit Anfragen mit bekannter Key-ID nicht auf ihn warten.
+	fetchMu sync.Mutex
+
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+	// fetchedAt ist der Zeitpunkt des letzten erfolgreichen Abrufs, attemptedAt der des
+	// letzten Versuchs und lastErr dessen Fehler.
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+}
+
+func newKeySet(cfg *Config) *keySet {
+	return &keySet{
+		file:   cfg.JWKSFile,
+		url:    cfg.JWKSURL,
+		ttl:    cfg.JWKSCacheTTL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// key liefert den öffentlichen Schlüssel zur Key-ID. Die JWKS werden nach Ablauf der TTL
+// neu geladen, bei einer unbekannten Key-ID (z.B. nach einer Schlüsselrotation) auch vorher.
+// Abrufe finden höchstens einmal pro minRefreshInterval statt, auch wenn der letzte
+// fehlgeschlagen ist.
+func (s *keySet) key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	keys := s.keys
+	expired := s.url != "" && time.Since(s.fetchedAt) > s.ttl
+	s.mu.Unlock()
+
+	if keys == nil || expired {
+		var err error
+		if keys, err = s.refresh(); err != nil && keys == nil {
+			return nil, err
+		}
+	}
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	keys, err := s.refresh()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// refresh lädt die JWKS neu, sofern der letzte Versuch länger als minRefreshInterval
+// zurückliegt, und liefert die aktuellen Schlüssel. Bei einem Fehler bleiben die bisherigen
+// Schlüssel erhalten und werden zusammen mit dem Fehler zurückgegeben.
+func (s *keySet) refresh() (map[string]crypto.PublicKey, error) {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	s.mu.Lock()
+	if !s.attemptedAt.IsZero() && time.Since(s.attemptedAt) < minRefreshInterval {
+		keys, err := s.keys, s.lastErr
+		s.mu.Unlock()
+		return keys, err
+	}
+	s.mu.Unlock()
+
+	keys, err := s.fetch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attemptedAt = time.Now()
+	s.lastErr = err
+	if err != nil {
+		return s.keys, err
+	}
+	s.keys = keys
+	s.fetchedAt = s.attemptedAt
+	return keys, nil
+}
+
+// fetch lädt und parst die JWKS. Schlüssel, die nicht zum Signieren dienen oder deren Typ
+// bzw. Kurve nicht unterstützt wird (z.B. OKP/Ed25519), werden übersprungen. Ein Fehler
+// entsteht erst, wenn kein verwendbarer Schlüssel übrig bleibt.
+func (s *keySet) fetch() (map[string]crypto.PublicKey, error) {
+	data, err := s.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			logging.Debugf("Skipping key %q in JWKS: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no usable signing key")
+	}
+	return keys, nil
+}
+
+func (s *keySet) load() ([]byte, error) {
+	if s.file != "" {
+		return os.ReadFile(s.file)
+	}
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, s.url)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// publicKey wandelt einen RSA- oder EC-Schlüssel in einen crypto.PublicKey um.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
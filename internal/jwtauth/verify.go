@@ -0,0 +1,228 @@
+// Package jwtauth prüft OAuth2-Bearer-Tokens (JWTs, z.B. von Entra ID oder Keycloak) gegen
+// eine lokal konfigurierte JWKS und leitet aus einem Claim die erlaubten App-Tags ab.
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken wird für alle Tokens zurückgegeben, die nicht akzeptiert werden.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal ist der durch ein Token authentifizierte Aufrufer.
+type Principal struct {
+	// Subject ist der Client (azp, appid oder sub) des Tokens.
+	Subject string
+	AppTags []string
+	// Admin ist gesetzt, wenn der App-Tag-Claim die konfigurierte AdminRole enthält.
+	Admin bool
+}
+
+// Verifier prüft Signatur, Aussteller, Zielgruppe und Gültigkeitszeitraum von JWTs.
+type Verifier struct {
+	cfg  *Config
+	keys *keySet
+	now  func() time.Time
+}
+
+// NewVerifier erstellt einen Verifier für die Konfiguration.
+func NewVerifier(cfg *Config) *Verifier {
+	return &Verifier{cfg: cfg, keys: newKeySet(cfg), now: time.Now}
+}
+
+// LooksLikeJWT unterscheidet JWTs von anderen Bearer-Tokens wie API-Keys.
+func LooksLikeJWT(token string) bool {
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify prüft ein Token und liefert den Aufrufer mit seinen App-Tags.
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	key, err := v.keys.key(h.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return &Principal{Subject: subject(claims), AppTags: v.appTags(claims), Admin: v.isAdmin(claims)}, nil
+}
+
+// checkClaims prüft iss, aud, exp und nbf.
+func (v *Verifier) checkClaims(claims map[string]interface{}) error {
+	if iss, _ := claims["iss"].(string); iss != v.cfg.Issuer {
+		return fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !contains(stringValues(claims["aud"]), v.cfg.Audience) {
+		return errors.New("token is not intended for this audience")
+	}
+
+	now := v.now()
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return errors.New("missing exp claim")
+	}
+	if now.After(exp.Add(v.cfg.Leeway)) {
+		return errors.New("token is expired")
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Add(v.cfg.Leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	return nil
+}
+
+// appTags leitet die erlaubten App-Tags aus dem konfigurierten Claim ab.
+func (v *Verifier) appTags(claims map[string]interface{}) []string {
+	var tags []string
+	for _, value := range stringValues(claims[v.cfg.AppTagClaim]) {
+		if v.cfg.AppTagMap != nil {
+			tags = append(tags, v.cfg.AppTagMap[value]...)
+			continue
+		}
+		if strings.HasPrefix(value, v.cfg.AppTagPrefix) && len(value) > len(v.cfg.AppTagPrefix) {
+			tags = append(tags, strings.TrimPrefix(value, v.cfg.AppTagPrefix))
+		}
+	}
+	return tags
+}
+
+// isAdmin prüft, ob der App-Tag-Claim die AdminRole enthält.
+func (v *Verifier) isAdmin(claims map[string]interface{}) bool {
+	return v.cfg.AdminRole != "" && contains(stringValues(claims[v.cfg.AppTagClaim]), v.cfg.AdminRole)
+}
+
+// verifySignature prüft eine JWS-Signatur für RS*, PS* und ES*.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch {
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %q does not match key type", alg)
+		}
+		if alg[:2] == "PS" {
+			return rsa.VerifyPSS(pub, hash, digest, signature, nil)
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case strings.HasPrefix(alg, "ES"):
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %q does not match key type", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// subject liefert die Client-Kennung eines Tokens (azp bzw. appid für Client-Credentials, sonst sub).
+func subject(claims map[string]interface{}) string {
+	for _, name := range []string{"azp", "appid", "sub"} {
+		if s, ok := claims[name].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// stringValues liefert einen Claim, der ein String oder ein Array von Strings sein kann, als Slice.
+func stringValues(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func numericDate(v interface{}) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,402 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "api://email-microservice"
+)
+
+// testKey ist ein lokal erzeugter Signaturschlüssel mit seiner Key-ID.
+type testKey struct {
+	kid     string
+	private crypto.Signer
+}
+
+func newRSAKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, private: key}
+}
+
+func newECKey(t *testing.T, kid string) testKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{kid: kid, private: key}
+}
+
+// jwk liefert den öffentlichen Teil des Schlüssels im JWKS-Format.
+func (k testKey) jwk() jwk {
+	switch pub := k.private.Public().(type) {
+	case *rsa.PublicKey:
+		return jwk{Kty: "RSA", Kid: k.kid, Use: "sig", N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return jwk{Kty: "EC", Kid: k.kid, Use: "sig", Crv: "P-256", X: b64(pub.X.FillBytes(make([]byte, size))), Y: b64(pub.Y.FillBytes(make([]byte, size)))}
+	}
+	panic("unsupported key type")
+}
+
+// sign erstellt ein JWT mit dem angegebenen Algorithmus im Header. Signiert wird immer passend
+// zum Schlüsseltyp, sodass ein abweichender alg-Header eine Verwechslung simuliert.
+func (k testKey) sign(t *testing.T, alg string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": k.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signingInput))
+	sum := digest.Sum(nil)
+
+	var signature []byte
+	var err error
+	switch key := k.private.(type) {
+	case *rsa.PrivateKey:
+		if strings.HasPrefix(alg, "PS") {
+			signature, err = rsa.SignPSS(rand.Reader, key, crypto.SHA256, sum, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, sum)
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + b64(signature)
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// jwksServer liefert die aktuellen Schlüssel per httptest und zählt die Abrufe.
+type jwksServer struct {
+	*httptest.Server
+	keys     atomic.Value // []jwk
+	requests atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys ...testKey) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.setKeys(keys...)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys.Load()})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...testKey) {
+	set := make([]jwk, len(keys))
+	for i, k := range keys {
+		set[i] = k.jwk()
+	}
+	s.keys.Store(set)
+}
+
+func testConfig(jwksURL string) *Config {
+	return &Config{
+		JWKSURL:      jwksURL,
+		JWKSCacheTTL: time.Hour,
+		Issuer:       testIssuer,
+		Audience:     testAudience,
+		Leeway:       time.Minute,
+		AppTagClaim:  "roles",
+		AppTagPrefix: "mail.send.",
+	}
+}
+
+// validClaims sind die Claims eines gültigen Tokens zum Zeitpunkt now.
+func validClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"azp":   "client-app",
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Minute).Unix(),
+		"roles": []string{"mail.send.rechnungssystem", "other.role"},
+	}
+}
+
+func TestVerifyValidTokens(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa-1")
+	ecKey := newECKey(t, "ec-1")
+	srv := newJWKSServer(t, rsaKey, ecKey)
+	v := NewVerifier(testConfig(srv.URL))
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		key  testKey
+		alg  string
+	}{
+		{"RS256", rsaKey, "RS256"},
+		{"PS256", rsaKey, "PS256"},
+		{"ES256", ecKey, "ES256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(tt.key.sign(t, tt.alg, validClaims(now)))
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			want := &Principal{Subject: "client-app", AppTags: []string{"rechnungssystem"}}
+			if !reflect.DeepEqual(p, want) {
+				t.Errorf("principal = %+v, want %+v", p, want)
+			}
+		})
+	}
+	if n := srv.requests.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+}
+
+func TestVerifyRejectsInvalidClaims(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, key)
+	v := NewVerifier(testConfig(srv.URL))
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		reason string
+	}{
+		{"expired", func(c map[string]interface{}) { c["exp"] = now.Add(-2 * time.Minute).Unix() }, "expired"},
+		{"missing exp", func(c map[string]interface{}) { delete(c, "exp") }, "missing exp"},
+		{"not yet valid", func(c map[string]interface{}) { c["nbf"] = now.Add(2 * time.Minute).Unix() }, "not valid yet"},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, "issuer"},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "api://other" }, "audience"},
+		{"audience list without match", func(c map[string]interface{}) { c["aud"] = []string{"api://a", "api://b"} }, "audience"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims(now)
+			tt.modify(claims)
+			_, err := v.Verify(key.sign(t, "RS256", claims))
+			if !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.reason) {
+				t.Fatalf("Verify: err = %v, want ErrInvalidToken mentioning %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestVerifyLeeway(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, key)
+	v := NewVerifier(testConfig(srv.URL))
+	now := time.Now()
+
+	claims := validClaims(now)
+	claims["exp"] = now.Add(-30 * time.Second).Unix()
+	claims["nbf"] = now.Add(30 * time.Second).Unix()
+	claims["aud"] = []string{"api://other", testAudience}
+	if _, err := v.Verify(key.sign(t, "RS256", claims)); err != nil {
+		t.Fatalf("Verify within leeway: %v", err)
+	}
+}
+
+func TestVerifyRejectsAlgorithmMismatch(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa-1")
+	ecKey := newECKey(t, "ec-1")
+	srv := newJWKSServer(t, rsaKey, ecKey)
+	v := NewVerifier(testConfig(srv.URL))
+	claims := validClaims(time.Now())
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"ES256 header with RSA key", rsaKey.sign(t, "ES256", claims)},
+		{"RS256 header with EC key", ecKey.sign(t, "RS256", claims)},
+		{"HS256", rsaKey.sign(t, "HS256", claims)},
+		{"none", rsaKey.sign(t, "none", claims)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify: err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsForeignSignature(t *testing.T) {
+	trusted := newRSAKey(t, "rsa-1")
+	attacker := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, trusted)
+	v := NewVerifier(testConfig(srv.URL))
+
+	if _, err := v.Verify(attacker.sign(t, "RS256", validClaims(time.Now()))); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify with foreign key: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestUnknownKeyIDRefreshesJWKS(t *testing.T) {
+	oldKey := newRSAKey(t, "old")
+	newKey := newRSAKey(t, "new")
+	srv := newJWKSServer(t, oldKey)
+	v := NewVerifier(testConfig(srv.URL))
+	claims := validClaims(time.Now())
+
+	if _, err := v.Verify(oldKey.sign(t, "RS256", claims)); err != nil {
+		t.Fatalf("Verify with old key: %v", err)
+	}
+
+	// Schlüsselrotation beim Aussteller: Innerhalb von minRefreshInterval wird nicht erneut geladen.
+	srv.setKeys(oldKey, newKey)
+	if _, err := v.Verify(newKey.sign(t, "RS256", claims)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify right after rotation: err = %v, want ErrInvalidToken", err)
+	}
+	if n := srv.requests.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times within minRefreshInterval, want 1", n)
+	}
+
+	// Danach führt die unbekannte Key-ID zu einem erneuten Abruf.
+	v.keys.mu.Lock()
+	v.keys.attemptedAt = time.Now().Add(-2 * minRefreshInterval)
+	v.keys.mu.Unlock()
+	if _, err := v.Verify(newKey.sign(t, "RS256", claims)); err != nil {
+		t.Fatalf("Verify after refresh: %v", err)
+	}
+	if n := srv.requests.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+
+	// Eine weiterhin unbekannte Key-ID wird abgelehnt.
+	if _, err := v.Verify(newRSAKey(t, "unknown").sign(t, "RS256", claims)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify with unknown kid: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWKSSkipsUnsupportedKeys(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t)
+	srv.keys.Store([]jwk{
+		{Kty: "OKP", Kid: "ed25519", Use: "sig", Crv: "Ed25519", X: b64([]byte("public-key"))},
+		{Kty: "EC", Kid: "secp256k1", Crv: "secp256k1", X: b64([]byte{1}), Y: b64([]byte{2})},
+		key.jwk(),
+	})
+	v := NewVerifier(testConfig(srv.URL))
+
+	if _, err := v.Verify(key.sign(t, "RS256", validClaims(time.Now()))); err != nil {
+		t.Fatalf("Verify with JWKS containing unsupported keys: %v", err)
+	}
+
+	// Ohne verwendbaren Schlüssel schlägt der Abruf fehl.
+	srv.keys.Store([]jwk{{Kty: "OKP", Kid: "ed25519", Crv: "Ed25519", X: b64([]byte("public-key"))}})
+	if _, err := newKeySet(testConfig(srv.URL)).fetch(); err == nil {
+		t.Fatal("fetch without usable key: err = nil, want error")
+	}
+}
+
+func TestJWKSOutageIsThrottled(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, key)
+	cfg := testConfig(srv.URL)
+	cfg.JWKSCacheTTL = time.Millisecond
+	v := NewVerifier(cfg)
+	token := key.sign(t, "RS256", validClaims(time.Now()))
+
+	if _, err := v.Verify(token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// Der Aussteller fällt aus: Nach Ablauf der TTL wird einmal erneut versucht, danach
+	// werden die bisherigen Schlüssel bis minRefreshInterval ohne weiteren Abruf verwendet.
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	v.keys.mu.Lock()
+	v.keys.attemptedAt = time.Now().Add(-2 * minRefreshInterval)
+	v.keys.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		if _, err := v.Verify(token); err != nil {
+			t.Fatalf("Verify #%d during outage: %v", i+1, err)
+		}
+	}
+	if n := srv.requests.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestJWKSFile(t *testing.T) {
+	key := newECKey(t, "ec-file")
+	data, err := json.Marshal(map[string]interface{}{"keys": []jwk{key.jwk()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig("")
+	cfg.JWKSFile = path
+	if _, err := NewVerifier(cfg).Verify(key.sign(t, "ES256", validClaims(time.Now()))); err != nil {
+		t.Fatalf("Verify with JWKS file: %v", err)
+	}
+}
+
+func TestAppTagMap(t *testing.T) {
+	key := newRSAKey(t, "rsa-1")
+	srv := newJWKSServer(t, key)
+	cfg := testConfig(srv.URL)
+	cfg.AppTagClaim = "appid"
+	cfg.AppTagMap = AppTagMap{"app-1": {"rechnungssystem", "kundenservice"}}
+	v := NewVerifier(cfg)
+
+	claims := validClaims(time.Now())
+	claims["appid"] = "app-1"
+	p, err := v.Verify(key.sign(t, "RS256", claims))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if want := []string{"rechnungssystem", "kundenservice"}; !reflect.DeepEqual(p.AppTags, want) {
+		t.Errorf("app tags = %v, want %v", p.AppTags, want)
+	}
+
+	claims["appid"] = "app-2"
+	p, err = v.Verify(key.sign(t, "RS256", claims))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(p.AppTags) != 0 {
+		t.Errorf("unmapped appid got app tags %v", p.AppTags)
+	}
+}
@@ -0,0 +1,244 @@
+// Package logging stellt levelbasiertes, strukturiertes Logging auf Basis von log/slog bereit.
+// Ausgaben erscheinen als JSON (Standard) oder als Text. Jede Ausgabe wird vor dem Schreiben
+// redigiert: Bearer-Tokens, JWTs, Werte von Schlüsseln wie client_secret oder password,
+// registrierte Geheimnisse und die lokalen Teile von E-Mail-Adressen erscheinen nie im Klartext
+// im Log.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Einheitliche Feldnamen für die Korrelation von Log-Meldungen.
+const (
+	FieldJobID          = "job_id"
+	FieldAppTag         = "app_tag"
+	FieldSender         = "sender"
+	FieldAttempt        = "attempt"
+	FieldStatusCode     = "status_code"
+	FieldGraphRequestID = "graph_request_id"
+	FieldTraceID        = "trace_id"
+	FieldCorrelationID  = "correlation_id"
+)
+
+// Ausgabeformate für LOG_FORMAT.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Level ist die Schwere einer Log-Meldung.
+type Level = slog.Level
+
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+)
+
+// ParseLevel wandelt einen Level-Namen (debug, info, warn, error) in einen Level um.
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+// ParseFormat prüft den Wert von LOG_FORMAT; leer bedeutet json.
+func ParseFormat(s string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(s)); format {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatText:
+		return format, nil
+	}
+	return FormatJSON, fmt.Errorf("unknown log format %q", s)
+}
+
+// Config ist die Logging-Konfiguration, z.B. als Teil von config.Config.
+type Config struct {
+	// Level ist debug, info, warn oder error.
+	Level string `config:"level" default:"info"`
+	// Format ist json oder text.
+	Format string `config:"format" default:"json"`
+	// EmailRedaction ist mask, hash oder none.
+	EmailRedaction string `config:"email_redaction" default:"mask"`
+	// HashSalt ist das Salz für EmailRedaction=hash.
+	HashSalt string `config:"hash_salt" secret:"true"`
+}
+
+// Validate prüft die Werte der Konfiguration.
+func (c Config) Validate() error {
+	if _, err := ParseLevel(c.Level); err != nil {
+		return err
+	}
+	if _, err := ParseFormat(c.Format); err != nil {
+		return err
+	}
+	if _, err := ParseEmailMode(c.EmailRedaction); err != nil {
+		return err
+	}
+	return nil
+}
+
+var (
+	mu       sync.RWMutex
+	level    = new(slog.LevelVar)
+	redactor = newRedactor(EmailMask, "")
+	// output ist das Ziel der Log-Ausgaben (Standard: stderr wie das log-Paket).
+	output io.Writer = os.Stderr
+	base             = slog.New(newHandler(FormatJSON))
+)
+
+// Setup konfiguriert Level, Format und Redaktion. Bei ungültigen Werten werden die
+// Standardwerte (info, json, mask) verwendet und ein Fehler zurückgegeben. Das log-Paket und
+// slog.Default schreiben anschließend ebenfalls über diesen Logger.
+func Setup(cfg Config) error {
+	lvl, levelErr := ParseLevel(cfg.Level)
+	format, formatErr := ParseFormat(cfg.Format)
+	mode, modeErr := ParseEmailMode(cfg.EmailRedaction)
+
+	mu.Lock()
+	level.Set(lvl)
+	redactor = newRedactor(mode, cfg.HashSalt)
+	redactor.secrets = append(redactor.secrets, registeredSecrets...)
+	base = slog.New(newHandler(format))
+	mu.Unlock()
+
+	slog.SetDefault(base)
+	// slog.SetDefault leitet das log-Paket um; Zeitstempel setzt bereits der Handler.
+	log.SetFlags(0)
+
+	for _, err := range []error{levelErr, formatErr, modeErr} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newHandler(format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if format == FormatText {
+		return slog.NewTextHandler(output, opts)
+	}
+	return slog.NewJSONHandler(output, opts)
+}
+
+// redactAttr redigiert die Meldung und alle Feldwerte außer Zahlen, Zeiten und Wahrheitswerten.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		a.Value = slog.StringValue(Redact(fmt.Sprint(a.Value.Any())))
+	}
+	return a
+}
+
+var registeredSecrets []string
+
+// RegisterSecret sorgt dafür, dass der Wert nie im Log erscheint, z.B. das Client-Secret aus der Konfiguration.
+func RegisterSecret(secret string) {
+	if len(secret) < 4 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	registeredSecrets = append(registeredSecrets, secret)
+	redactor.secrets = append(redactor.secrets, secret)
+}
+
+// Redact wendet die Redaktionsregeln auf einen beliebigen Text an.
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return redactor.redact(s)
+}
+
+// Logger schreibt Meldungen mit festen strukturierten Feldern.
+type Logger struct {
+	fields []any
+}
+
+// With liefert einen Logger mit den Feldern aus den Schlüssel-Wert-Paaren kv, z.B. With("job_id", 42).
+// Leere Zeichenketten werden ausgelassen, damit optionale Felder wie correlation_id nicht leer erscheinen.
+func With(kv ...any) *Logger {
+	return (&Logger{}).With(kv...)
+}
+
+// With liefert einen neuen Logger mit zusätzlichen Feldern.
+func (l *Logger) With(kv ...any) *Logger {
+	fields := make([]any, len(l.fields), len(l.fields)+len(kv))
+	copy(fields, l.fields)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if s, ok := kv[i+1].(string); ok && s == "" {
+			continue
+		}
+		fields = append(fields, fmt.Sprint(kv[i]), kv[i+1])
+	}
+	return &Logger{fields: fields}
+}
+
+func (l *Logger) Debugf(format string, args ...any) { l.output(LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...any)  { l.output(LevelInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...any)  { l.output(LevelWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...any) { l.output(LevelError, format, args...) }
+
+// Fatalf schreibt eine Fehlermeldung und beendet das Programm.
+func (l *Logger) Fatalf(format string, args ...any) {
+	l.output(LevelError, format, args...)
+	os.Exit(1)
+}
+
+func (l *Logger) output(lvl Level, format string, args ...any) {
+	mu.RLock()
+	logger := base
+	mu.RUnlock()
+
+	ctx := context.Background()
+	if !logger.Enabled(ctx, lvl) {
+		return
+	}
+	logger.Log(ctx, lvl, fmt.Sprintf(format, args...), l.fields...)
+}
+
+type contextKey struct{}
+
+// NewContext hängt den Logger an ctx, z.B. mit der Korrelations-ID einer Anfrage.
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext liefert den Logger aus ctx oder einen Logger ohne Felder.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return std
+}
+
+var std = &Logger{}
+
+func Debugf(format string, args ...any) { std.output(LevelDebug, format, args...) }
+func Infof(format string, args ...any)  { std.output(LevelInfo, format, args...) }
+func Warnf(format string, args ...any)  { std.output(LevelWarn, format, args...) }
+func Errorf(format string, args ...any) { std.output(LevelError, format, args...) }
+
+// Fatalf schreibt eine Fehlermeldung und beendet das Programm.
+func Fatalf(format string, args ...any) { std.Fatalf(format, args...) }
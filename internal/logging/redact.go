@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redacted ersetzt geheime Werte im Log.
+const redacted = "[REDACTED]"
+
+// EmailMode legt fest, wie lokale Teile von E-Mail-Adressen im Log erscheinen.
+type EmailMode string
+
+const (
+	// EmailMask ersetzt den lokalen Teil durch "***" (max@example.com -> ***@example.com).
+	EmailMask EmailMode = "mask"
+	// EmailHash ersetzt den lokalen Teil durch einen gekürzten, gesalzenen SHA-256-Hash, sodass
+	// Meldungen zur selben Adresse korreliert werden können.
+	EmailHash EmailMode = "hash"
+	// EmailNone lässt Adressen unverändert.
+	EmailNone EmailMode = "none"
+)
+
+// ParseEmailMode wandelt den Wert von LOG_EMAIL_REDACTION um; leer bedeutet mask.
+func ParseEmailMode(s string) (EmailMode, error) {
+	switch mode := EmailMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return EmailMask, nil
+	case EmailMask, EmailHash, EmailNone:
+		return mode, nil
+	}
+	return EmailMask, fmt.Errorf("unknown email redaction mode %q", s)
+}
+
+var (
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	jwtPattern    = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// secretPattern erkennt Schlüssel-Wert-Paare in Formularen, JSON und key=value-Ausgaben.
+	secretPattern = regexp.MustCompile(`(?i)("?(?:client_secret|client_assertion|access_token|refresh_token|id_token|password|api_key|secret)"?\s*[:=]\s*"?)([^"&\s,}]+)`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9.!#$%&'*+/?^_{|}~-]+@([A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)+)`)
+)
+
+type redactorConfig struct {
+	emailMode EmailMode
+	salt      string
+	secrets   []string
+}
+
+func newRedactor(mode EmailMode, salt string) *redactorConfig {
+	return &redactorConfig{emailMode: mode, salt: salt}
+}
+
+func (r *redactorConfig) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+redacted)
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = secretPattern.ReplaceAllString(s, "${1}"+redacted)
+
+	switch r.emailMode {
+	case EmailMask:
+		s = emailPattern.ReplaceAllString(s, "***@$1")
+	case EmailHash:
+		s = emailPattern.ReplaceAllStringFunc(s, func(addr string) string {
+			at := strings.LastIndex(addr, "@")
+			sum := sha256.Sum256([]byte(r.salt + strings.ToLower(addr[:at])))
+			return "h:" + hex.EncodeToString(sum[:5]) + addr[at:]
+		})
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+
+	"email-microservice/internal/models"
+)
+
+// MaxRecipients begrenzt die Anzahl der Empfänger eines Mail-Merge-Jobs. Der Worker legt
+// alle Einzeljobs in einem Durchlauf an; die Grenze hält diesen Durchlauf deutlich unter der
+// AckWait des Consumers (30s), auch wenn er zusätzlich per InProgress verlängert wird.
+const MaxRecipients = 2000
+
+// CheckTemplate prüft, ob das Template eines Feldes (subject, body_content oder
+// html_body_content) parsebar ist. html_body_content wird als html/template geprüft.
+func CheckTemplate(field, tmpl string) error {
+	var err error
+	if field == "html_body_content" {
+		_, err = htmltemplate.New(field).Parse(tmpl)
+	} else {
+		_, err = texttemplate.New(field).Parse(tmpl)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return nil
+}
+
+// Render erzeugt aus einem Mail-Merge-Job den Einzeljob für den Empfänger mit dem angegebenen Index.
+// Betreff und Textkörper werden mit text/template, der HTML-Körper mit html/template gerendert,
+// sodass Variablen im HTML escaped werden. Fehlende Variablen führen zu einem Fehler.
+func Render(parent *models.EmailJob, index int) (models.EmailJob, error) {
+	r := parent.MergeRecipients[index]
+
+	subject, err := renderText("subject", parent.Subject, r.Variables)
+	if err != nil {
+		return models.EmailJob{}, err
+	}
+	bodyContent, err := renderText("body", parent.BodyContent, r.Variables)
+	if err != nil {
+		return models.EmailJob{}, err
+	}
+	htmlBodyContent, err := renderHTML(parent.HtmlBodyContent, r.Variables)
+	if err != nil {
+		return models.EmailJob{}, err
+	}
+
+	return models.EmailJob{
+		ParentID:               parent.ID,
+		Recipients:             []string{r.Address},
+		Subject:                subject,
+		BodyContent:            bodyContent,
+		HtmlBodyContent:        htmlBodyContent,
+		Attachments:            parent.Attachments,
+		AppTag:                 parent.AppTag,
+		Priority:               parent.Priority,
+		Headers:                parent.Headers,
+		ReplyTo:                parent.ReplyTo,
+		Importance:             parent.Importance,
+		RequestReadReceipt:     parent.RequestReadReceipt,
+		RequestDeliveryReceipt: parent.RequestDeliveryReceipt,
+		Categories:             parent.Categories,
+		SaveToSentItems:        parent.SaveToSentItems,
+		IgnoreSuppression:      parent.IgnoreSuppression,
+		TraceContext:           parent.TraceContext,
+		CorrelationID:          parent.CorrelationID,
+	}, nil
+}
+
+func renderText(name, tmpl string, vars map[string]string) (string, error) {
+	if tmpl == "" {
+		return "", nil
+	}
+	t, err := texttemplate.New(name).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+func renderHTML(tmpl string, vars map[string]string) (string, error) {
+	if tmpl == "" {
+		return "", nil
+	}
+	t, err := htmltemplate.New("html").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid html template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("could not render html: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,103 @@
+// Package metrics stellt die Prometheus-Metriken von API und Worker bereit.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+
+	"email-microservice/internal/logging"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Ereignisse im Lebenszyklus eines Jobs (Label "event" von email_jobs_total).
+const (
+	// EventAccepted: die API hat den Job angenommen und in den Stream gestellt.
+	EventAccepted = "accepted"
+	// EventSent: Graph hat die Mail angenommen.
+	EventSent = "sent"
+	// EventThrottled: Graph hat mit 429 geantwortet, der Versand wird wiederholt.
+	EventThrottled = "throttled"
+	// EventFailed: alle Versuche sind fehlgeschlagen, der Job wird später erneut zugestellt.
+	EventFailed = "failed"
+	// EventDeadLettered: der Job ist endgültig fehlgeschlagen und wird verworfen.
+	EventDeadLettered = "dead_lettered"
+)
+
+var (
+	// Jobs zählt Jobs nach Ereignis, App-Tag und Absender. Bei "accepted" ist der Absender leer,
+	// da die API ihn nicht kennt.
+	Jobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "email",
+		Name:      "jobs_total",
+		Help:      "Email jobs by lifecycle event, app tag and sender.",
+	}, []string{"event", "app_tag", "sender"})
+
+	// GraphRequestDuration misst die Dauer der Aufrufe an Graph und den Token-Endpunkt.
+	GraphRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "email",
+		Name:      "graph_request_duration_seconds",
+		Help:      "Latency of Microsoft Graph and token endpoint requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"operation", "status"})
+
+	// QueueTime misst die Zeit vom Einstellen in den Stream bis zum erfolgreichen Versand.
+	QueueTime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "email",
+		Name:      "queue_time_seconds",
+		Help:      "Time from publishing a job to the EMAILS stream until Graph accepted the mail.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+	}, []string{"app_tag"})
+
+	// ConsumerPending und ConsumerAckPending spiegeln den Zustand der Pull-Consumer.
+	ConsumerPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "email",
+		Name:      "consumer_pending_messages",
+		Help:      "Messages in the EMAILS stream not yet delivered to the consumer.",
+	}, []string{"consumer"})
+	ConsumerAckPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "email",
+		Name:      "consumer_ack_pending_messages",
+		Help:      "Messages delivered to the consumer but not yet acknowledged.",
+	}, []string{"consumer"})
+
+	// TokenRefreshes zählt Anfragen an den Token-Endpunkt nach Ergebnis ("success" oder "error").
+	TokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "email",
+		Name:      "graph_token_refreshes_total",
+		Help:      "Access token requests to the Microsoft identity platform by result.",
+	}, []string{"result"})
+)
+
+// JobEvent zählt ein Ereignis eines Jobs.
+func JobEvent(event, appTag, sender string) {
+	Jobs.WithLabelValues(event, appTag, sender).Inc()
+}
+
+// StatusLabel liefert das Label "status" für eine HTTP-Antwort bzw. "error", falls keine Antwort kam.
+func StatusLabel(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode)
+}
+
+// Handler liefert den HTTP-Handler für /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve startet im Hintergrund einen HTTP-Server, der /metrics und die übrigen Routen von mux
+// (z.B. /healthz) auf addr (METRICS_ADDR, Standard :9090) bereitstellt. Für Dienste ohne
+// eigenen HTTP-Server wie den Worker.
+func Serve(addr string, mux *http.ServeMux) {
+	mux.Handle("/metrics", Handler())
+	go func() {
+		logging.Infof("Serving metrics on %s/metrics", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logging.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+}
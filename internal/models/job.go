@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Attachment defines the structure for email attachments.
 type Attachment struct {
 	ODataType    string `json:"@odata.type"`
@@ -8,8 +10,16 @@ type Attachment struct {
 	ContentType  string `json:"contentType"`
 }
 
+// MergeRecipient is a single recipient of a mail merge job with its own template variables.
+type MergeRecipient struct {
+	Address   string            `json:"address"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
 // EmailJob represents an email sending job received via NATS.
 type EmailJob struct {
+	// ID ist die ID des Eintrags in 'mail_jobs'. Sie wird von der API vergeben.
+	ID              int64        `json:"id,omitempty"`
 	Recipients      []string     `json:"recipients"`
 	CcRecipients    []string     `json:"cc_recipients,omitempty"`
 	BccRecipients   []string     `json:"bcc_recipients,omitempty"`
@@ -18,14 +28,114 @@ type EmailJob struct {
 	HtmlBodyContent string       `json:"html_body_content,omitempty"`
 	Attachments     []Attachment `json:"attachments,omitempty"`
 	AppTag          string       `json:"app_tag"`
+	// Priority steuert die Lane im EMAILS-Stream: "high", "normal" (Standard) oder "low".
+	Priority string `json:"priority,omitempty"`
+	// Headers sind benutzerdefinierte Internet-Header (Namen mit "X-", z.B. X-Correlation-ID).
+	// X-Job-ID und X-App-Tag setzt der Worker selbst.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// MergeRecipients macht den Job zu einem Mail-Merge-Job: Subject und Bodies sind dann
+	// Templates, die der Worker pro Empfänger rendert und als eigene Jobs versendet.
+	MergeRecipients []MergeRecipient `json:"merge_recipients,omitempty"`
+	// Optionale Nachrichteneigenschaften. Nicht gesetzte Werte werden aus den Standardwerten
+	// des Senders (App-Tag) übernommen.
+	ReplyTo                []string `json:"reply_to,omitempty"`
+	Importance             string   `json:"importance,omitempty"`
+	RequestReadReceipt     bool     `json:"request_read_receipt,omitempty"`
+	RequestDeliveryReceipt bool     `json:"request_delivery_receipt,omitempty"`
+	Categories             []string `json:"categories,omitempty"`
+	SaveToSentItems        *bool    `json:"save_to_sent_items,omitempty"`
+
+	// IgnoreSuppression versendet auch an Adressen auf der Suppression-Liste. Nur für streng
+	// transaktionale Mails gedacht und daher nur zusammen mit Priorität "high" zulässig.
+	IgnoreSuppression bool `json:"ignore_suppression,omitempty"`
+	// ParentID verweist bei aus einem Mail-Merge erzeugten Jobs auf den ursprünglichen Job.
+	ParentID int64 `json:"parent_id,omitempty"`
 
 	// KORREKTUR: Feld zur Aufnahme des Trace-Kontexts von Datadog hinzugefügt.
 	TraceContext map[string]string `json:"trace_context,omitempty"`
+	// CorrelationID stammt aus dem Header X-Correlation-ID der API-Anfrage (oder wird von der API
+	// erzeugt) und erscheint als correlation_id in allen Log-Meldungen zu diesem Job.
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// JobInfo describes the status of a recorded email job. SuppressedRecipients lists recipients
+// dropped because of the suppression list, BouncedRecipients those reported as undeliverable
+// by a non-delivery report. For mail merge jobs Children holds the status of every recipient.
+type JobInfo struct {
+	ID                   int64      `json:"id"`
+	ParentID             int64      `json:"parent_id,omitempty"`
+	AppTag               string     `json:"app_tag"`
+	Recipients           []string   `json:"recipients"`
+	Status               string     `json:"status"`
+	ErrorMessage         string     `json:"error_message,omitempty"`
+	SuppressedRecipients []string   `json:"suppressed_recipients,omitempty"`
+	BouncedRecipients    []string   `json:"bounced_recipients,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	ProcessedAt          *time.Time `json:"processed_at,omitempty"`
+	Children             []JobInfo  `json:"children,omitempty"`
 }
 
-// Sender represents a sender entry from the database.
+// Sender represents a sender entry from the database. Email is the From address and Mailbox
+// the mailbox used to send; if they differ the mail is sent as Email. TenantID references the
+// tenant whose app credentials are used; nil means the default credentials from the
+// environment, where an empty Mailbox means SENDER_EMAIL. For tenant senders an empty Mailbox
+// falls back to Email, never to SENDER_EMAIL. SenderAddress optionally sets the Sender for
+// send-on-behalf. ReplyTo, Importance and SaveToSentItems are the defaults for jobs of this
+// app tag.
 type Sender struct {
-	ID     int64  `json:"id"`
-	AppTag string `json:"app_tag"`
-	Email  string `json:"email"`
+	ID              int64  `json:"id"`
+	AppTag          string `json:"app_tag"`
+	TenantID        *int64 `json:"tenant_id"`
+	Email           string `json:"email"`
+	Mailbox         string `json:"mailbox"`
+	SenderAddress   string `json:"sender_address"`
+	ReplyTo         string `json:"reply_to"`
+	Importance      string `json:"importance"`
+	SaveToSentItems bool   `json:"save_to_sent_items"`
+}
+
+// Tenant holds the Azure AD app credentials of a Microsoft 365 tenant (e.g. a subsidiary)
+// that senders can send from. If CertificatePath and PrivateKeyPath reference PEM files on the
+// worker hosts, the app authenticates with a certificate instead of ClientSecret.
+type Tenant struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	AzureTenantID   string    `json:"azure_tenant_id"`
+	ClientID        string    `json:"client_id"`
+	ClientSecret    string    `json:"client_secret"`
+	CertificatePath string    `json:"certificate_path"`
+	PrivateKeyPath  string    `json:"private_key_path"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// APIKey is a client API key. Only a hash of the key is stored; Prefix identifies the key in
+// listings. A key may only send and read jobs of its AppTags.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	AppTags    []string   `json:"app_tags"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// PolicyRule is an allow or block rule for recipients of an app tag.
+type PolicyRule struct {
+	ID       int64  `json:"id"`
+	AppTag   string `json:"app_tag"`
+	RuleType string `json:"rule_type"`
+	Pattern  string `json:"pattern"`
+}
+
+// Suppression is an address that must not receive mail, e.g. after a hard bounce or an
+// unsubscribe. Entries without ExpiresAt never expire.
+type Suppression struct {
+	ID        int64      `json:"id"`
+	Address   string     `json:"address"`
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
@@ -1,34 +1,73 @@
 package nats
 
 import (
-	"log"
+	"email-microservice/internal/logging"
 
 	"github.com/nats-io/nats.go"
 )
 
 const (
-	StreamName  = "EMAILS"
-	StreamSubj  = "EMAILS.*"
+	StreamName = "EMAILS"
+	StreamSubj = "EMAILS.>"
+
+	// EmailToSend ist das Subject für Jobs mit normaler Priorität.
 	EmailToSend = "EMAILS.send"
+	// EmailToSendHigh ist das Subject für transaktionale Mails (z.B. Passwort-Resets).
+	EmailToSendHigh = "EMAILS.send.high"
+	// EmailToSendLow ist das Subject für Massenversand (z.B. Reports, Newsletter).
+	EmailToSendLow = "EMAILS.send.low"
+)
+
+// Prioritäten, die ein EmailJob tragen kann.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
 )
 
+// SubjectForPriority liefert das Subject, auf das ein Job mit der angegebenen Priorität
+// publiziert wird. Leere oder unbekannte Prioritäten landen im normalen Subject.
+func SubjectForPriority(priority string) string {
+	switch priority {
+	case PriorityHigh:
+		return EmailToSendHigh
+	case PriorityLow:
+		return EmailToSendLow
+	default:
+		return EmailToSend
+	}
+}
+
+// ValidPriority prüft, ob die Priorität bekannt ist. Eine leere Priorität gilt als normal.
+func ValidPriority(priority string) bool {
+	switch priority {
+	case "", PriorityHigh, PriorityNormal, PriorityLow:
+		return true
+	}
+	return false
+}
+
 func Setup(natsURL string) (*nats.Conn, nats.JetStreamContext) {
 	nc, err := nats.Connect(natsURL)
 	if err != nil {
-		log.Fatalf("Error connecting to NATS: %v", err)
+		logging.Fatalf("Error connecting to NATS: %v", err)
 	}
 
 	js, err := nc.JetStream()
 	if err != nil {
-		log.Fatalf("Error creating JetStream context: %v", err)
+		logging.Fatalf("Error creating JetStream context: %v", err)
 	}
 
-	_, err = js.AddStream(&nats.StreamConfig{
+	streamCfg := &nats.StreamConfig{
 		Name:     StreamName,
 		Subjects: []string{StreamSubj},
-	})
+	}
+	_, err = js.AddStream(streamCfg)
 	if err != nil {
-		log.Printf("Warning: Could not create stream (it likely already exists): %v", err)
+		// Bestehende Streams (z.B. noch mit "EMAILS.*") auf die aktuellen Subjects aktualisieren.
+		if _, uErr := js.UpdateStream(streamCfg); uErr != nil {
+			logging.Warnf("Could not create or update stream: %v / %v", err, uErr)
+		}
 	}
 
 	return nc, js
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"email-microservice/internal/address"
+	"email-microservice/internal/models"
+)
+
+// Regeltypen einer Empfänger-Policy.
+const (
+	RuleAllow = "allow"
+	RuleBlock = "block"
+)
+
+// Policy enthält die erlaubten und gesperrten Empfängermuster eines App-Tags.
+//
+// Ein Muster mit '@' wird gegen die vollständige Adresse geprüft (z.B. "*@example.com",
+// "max@example.com"), alle anderen gegen die Domain (z.B. "example.com", "*.example.com").
+// '*' steht für beliebig viele Zeichen. Gesperrte Muster haben Vorrang; gibt es mindestens
+// ein erlaubtes Muster, muss jede Adresse auf eines davon passen.
+type Policy struct {
+	Allow []string
+	Block []string
+}
+
+// FromRules baut eine Policy aus den Regeln der Datenbank.
+func FromRules(rules []models.PolicyRule) *Policy {
+	p := &Policy{}
+	for _, r := range rules {
+		switch r.RuleType {
+		case RuleAllow:
+			p.Allow = append(p.Allow, r.Pattern)
+		case RuleBlock:
+			p.Block = append(p.Block, r.Pattern)
+		}
+	}
+	return p
+}
+
+// ValidRuleType prüft, ob der Regeltyp bekannt ist.
+func ValidRuleType(ruleType string) bool {
+	return ruleType == RuleAllow || ruleType == RuleBlock
+}
+
+// ValidatePattern prüft ein Muster, bevor es gespeichert wird.
+func ValidatePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("pattern is empty")
+	}
+	if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// Check prüft eine einzelne Adresse gegen die Policy.
+func (p *Policy) Check(addr address.Address) error {
+	for _, pattern := range p.Block {
+		if matches(pattern, addr) {
+			return fmt.Errorf("recipient %s is blocked by pattern %q", addr.Email, pattern)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if matches(pattern, addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("recipient %s is not in the allowlist", addr.Email)
+}
+
+// Violation ist eine Adresse, die gegen die Policy verstößt, mit ihrem Index in der Liste.
+type Violation struct {
+	Index int
+	Err   error
+}
+
+// CheckAll prüft alle Adressen einer Liste. Nicht parsebare Adressen werden übersprungen,
+// da sie bereits bei der Validierung abgelehnt werden.
+func (p *Policy) CheckAll(recipients []string) []Violation {
+	var violations []Violation
+	for i, raw := range recipients {
+		addr, err := address.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if err := p.Check(addr); err != nil {
+			violations = append(violations, Violation{Index: i, Err: err})
+		}
+	}
+	return violations
+}
+
+func matches(pattern string, addr address.Address) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	subject := addr.Domain()
+	if strings.Contains(pattern, "@") {
+		subject = addr.Key()
+	}
+	ok, err := path.Match(pattern, subject)
+	return err == nil && ok
+}
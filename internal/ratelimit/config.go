@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bereiche, pro denen gezählt wird.
+const (
+	// ScopeClient zählt pro API-Client (API-Key bzw. JWT-Subject).
+	ScopeClient = "client"
+	// ScopeAppTag zählt pro App-Tag, unabhängig vom Client.
+	ScopeAppTag = "app_tag"
+)
+
+// Limits sind die Grenzen für einen Client bzw. App-Tag. 0 bedeutet unbegrenzt.
+type Limits struct {
+	PerMinute  int `config:"per_minute"`
+	DailyQuota int `config:"daily_quota"`
+}
+
+// Config legt fest, wie gezählt wird und welche Grenzen gelten. Die Werte werden von
+// config.Load gelesen (RATE_LIMIT_SCOPE, RATE_LIMIT_PER_MINUTE, RATE_LIMIT_DAILY_QUOTA und
+// RATE_LIMIT_OVERRIDES); ohne Werte sind keine Grenzen aktiv.
+type Config struct {
+	// Scope ist client oder app_tag.
+	Scope     string    `config:"scope" default:"client"`
+	Default   Limits    `config:",inline"`
+	Overrides Overrides `config:"overrides"`
+}
+
+// Enabled gibt an, ob überhaupt eine Grenze konfiguriert ist.
+func (c *Config) Enabled() bool {
+	if c.Default != (Limits{}) {
+		return true
+	}
+	for _, l := range c.Overrides {
+		if l != (Limits{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitsFor liefert die Grenzen für einen Client bzw. App-Tag.
+func (c *Config) LimitsFor(id string) Limits {
+	if l, ok := c.Overrides[id]; ok {
+		return l
+	}
+	return c.Default
+}
+
+// Validate prüft Bereich und Grenzen.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Scope != ScopeClient && c.Scope != ScopeAppTag {
+		errs = append(errs, fmt.Errorf("ungültiger RATE_LIMIT_SCOPE %q: erlaubt sind client oder app_tag", c.Scope))
+	}
+	if c.Default.PerMinute < 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_PER_MINUTE darf nicht negativ sein"))
+	}
+	if c.Default.DailyQuota < 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_DAILY_QUOTA darf nicht negativ sein"))
+	}
+	return errors.Join(errs...)
+}
+
+// Overrides sind abweichende Grenzen pro Client bzw. App-Tag. In Datei und Umgebung haben sie
+// das Format "name=pro_minute/pro_tag;name2=pro_minute/pro_tag".
+type Overrides map[string]Limits
+
+func (o *Overrides) UnmarshalText(text []byte) error {
+	parsed, err := ParseOverrides(string(text))
+	if err != nil {
+		return err
+	}
+	*o = parsed
+	return nil
+}
+
+func (o Overrides) MarshalText() ([]byte, error) {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]string, len(names))
+	for i, name := range names {
+		entries[i] = fmt.Sprintf("%s=%d/%d", name, o[name].PerMinute, o[name].DailyQuota)
+	}
+	return []byte(strings.Join(entries, ";")), nil
+}
+
+// ParseOverrides liest abweichende Grenzen im Format "name=pro_minute/pro_tag;...".
+func ParseOverrides(s string) (Overrides, error) {
+	overrides := make(Overrides)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, limits, ok := strings.Cut(entry, "=")
+		perMinute, daily, ok2 := strings.Cut(limits, "/")
+		name = strings.TrimSpace(name)
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("eintrag %q muss die Form name=pro_minute/pro_tag haben", entry)
+		}
+
+		var l Limits
+		var err error
+		if l.PerMinute, err = parseLimit(perMinute); err != nil {
+			return nil, fmt.Errorf("eintrag %q: %w", entry, err)
+		}
+		if l.DailyQuota, err = parseLimit(daily); err != nil {
+			return nil, fmt.Errorf("eintrag %q: %w", entry, err)
+		}
+		overrides[name] = l
+	}
+	return overrides, nil
+}
+
+func parseLimit(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("ungültiger Grenzwert %q", s)
+	}
+	return n, nil
+}
@@ -0,0 +1,146 @@
+// Package ratelimit begrenzt die Anzahl der Jobs pro Client bzw. App-Tag mit einem Limit pro
+// Minute und einer Tagesquote. Die Zähler liegen in NATS-KV-Buckets, damit alle API-Replicas
+// dieselben Werte sehen.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// MinuteBucket enthält die Zähler pro Minute; die Einträge verfallen nach kurzer Zeit.
+	MinuteBucket = "EMAIL_RATE_MINUTE"
+	// DailyBucket enthält die Zähler pro Tag (UTC).
+	DailyBucket = "EMAIL_RATE_DAILY"
+
+	// maxAttempts begrenzt die Wiederholungen bei gleichzeitigen Aktualisierungen eines Zählers.
+	maxAttempts = 10
+)
+
+// invalidKeyChars sind Zeichen, die in NATS-KV-Schlüsseln nicht erlaubt sind.
+var invalidKeyChars = regexp.MustCompile(`[^-_=/.a-zA-Z0-9]`)
+
+// Window ist der Stand eines Zählers nach einer Anfrage.
+type Window struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Result ist das Ergebnis einer Prüfung. Minute und Daily sind nil, wenn die jeweilige Grenze
+// nicht konfiguriert ist.
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Minute     *Window
+	Daily      *Window
+}
+
+// Limiter zählt Jobs in NATS KV und prüft sie gegen die konfigurierten Grenzen.
+type Limiter struct {
+	cfg    *Config
+	minute nats.KeyValue
+	daily  nats.KeyValue
+	now    func() time.Time
+}
+
+// New erstellt einen Limiter und legt die KV-Buckets an, falls sie noch nicht existieren.
+func New(js nats.JetStreamContext, cfg *Config) (*Limiter, error) {
+	minute, err := bucket(js, MinuteBucket, 2*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	daily, err := bucket(js, DailyBucket, 48*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{cfg: cfg, minute: minute, daily: daily, now: time.Now}, nil
+}
+
+func bucket(js nats.JetStreamContext, name string, ttl time.Duration) (nats.KeyValue, error) {
+	kv, err := js.KeyValue(name)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{Bucket: name, TTL: ttl, History: 1})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open key-value bucket %s: %w", name, err)
+	}
+	return kv, nil
+}
+
+// Scope liefert den konfigurierten Bereich (client oder app_tag).
+func (l *Limiter) Scope() string {
+	return l.cfg.Scope
+}
+
+// Allow zählt cost Jobs für id und prüft, ob die Grenzen eingehalten sind. Es wird mit festen
+// Fenstern gezählt (Kalenderminute bzw. Kalendertag in UTC); auch abgelehnte Anfragen werden
+// im jeweiligen Fenster mitgezählt.
+func (l *Limiter) Allow(id string, cost int) (*Result, error) {
+	limits := l.cfg.LimitsFor(id)
+	now := l.now().UTC()
+	res := &Result{Allowed: true}
+	key := invalidKeyChars.ReplaceAllString(id, "_")
+
+	if limits.PerMinute > 0 {
+		start := now.Truncate(time.Minute)
+		w, err := l.count(l.minute, key+"."+strconv.FormatInt(start.Unix(), 10), cost, limits.PerMinute, start.Add(time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		res.Minute = w
+		if w.Remaining < 0 {
+			res.Allowed = false
+			res.RetryAfter = w.Reset.Sub(now)
+		}
+	}
+
+	if res.Allowed && limits.DailyQuota > 0 {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		w, err := l.count(l.daily, key+"."+start.Format("20060102"), cost, limits.DailyQuota, start.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		res.Daily = w
+		if w.Remaining < 0 {
+			res.Allowed = false
+			res.RetryAfter = w.Reset.Sub(now)
+		}
+	}
+	return res, nil
+}
+
+// count erhöht einen Zähler atomar per optimistischer Nebenläufigkeitskontrolle.
+func (l *Limiter) count(kv nats.KeyValue, key string, cost, limit int, reset time.Time) (*Window, error) {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		entry, err := kv.Get(key)
+		var value int
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			if _, err := kv.Create(key, []byte(strconv.Itoa(cost))); err == nil {
+				value = cost
+			} else if errors.Is(err, nats.ErrKeyExists) {
+				continue
+			} else {
+				return nil, fmt.Errorf("could not create counter %s: %w", key, err)
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read counter %s: %w", key, err)
+		} else {
+			current, _ := strconv.Atoi(string(entry.Value()))
+			value = current + cost
+			if _, err := kv.Update(key, []byte(strconv.Itoa(value)), entry.Revision()); errors.Is(err, nats.ErrKeyExists) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("could not update counter %s: %w", key, err)
+			}
+		}
+		return &Window{Limit: limit, Remaining: limit - value, Reset: reset}, nil
+	}
+	return nil, fmt.Errorf("could not update counter %s: too many concurrent updates", key)
+}
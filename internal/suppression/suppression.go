@@ -0,0 +1,120 @@
+package suppression
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"email-microservice/internal/address"
+	"email-microservice/internal/models"
+)
+
+// Gründe für einen Eintrag auf der Suppression-Liste.
+const (
+	ReasonHardBounce  = "hard_bounce"
+	ReasonUnsubscribe = "unsubscribe"
+	ReasonComplaint   = "complaint"
+	ReasonManual      = "manual"
+)
+
+// Quellen, aus denen ein Eintrag stammt.
+const (
+	SourceAPI    = "api"
+	SourceAdmin  = "admin"
+	SourceImport = "import"
+	// SourceNDR kennzeichnet Einträge aus ausgewerteten Unzustellbarkeitsberichten.
+	SourceNDR = "ndr"
+)
+
+// csvHeader ist die Kopfzeile für Import und Export.
+var csvHeader = []string{"address", "reason", "source", "expires_at"}
+
+// ValidReason prüft, ob der Grund bekannt ist.
+func ValidReason(reason string) bool {
+	switch reason {
+	case ReasonHardBounce, ReasonUnsubscribe, ReasonComplaint, ReasonManual:
+		return true
+	}
+	return false
+}
+
+// Normalize prüft einen Eintrag und speichert die Adresse in der Form, in der sie
+// nachgeschlagen wird (ohne Anzeigenamen, kleingeschrieben).
+func Normalize(s *models.Suppression) error {
+	addr, err := address.Parse(s.Address)
+	if err != nil {
+		return err
+	}
+	s.Address = addr.Key()
+	if s.Reason == "" {
+		s.Reason = ReasonManual
+	}
+	if !ValidReason(s.Reason) {
+		return fmt.Errorf("unknown reason %q", s.Reason)
+	}
+	return nil
+}
+
+// ReadCSV liest Einträge im Format "address,reason,source,expires_at". Die Kopfzeile ist optional,
+// expires_at ist leer oder ein RFC3339-Zeitstempel. Fehlt source, wird defaultSource verwendet.
+func ReadCSV(r io.Reader, defaultSource string) ([]models.Suppression, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var entries []models.Suppression
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), csvHeader[0]) {
+			continue
+		}
+
+		s := models.Suppression{Address: record[0], Source: defaultSource}
+		if len(record) > 1 {
+			s.Reason = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			s.Source = strings.TrimSpace(record[2])
+		}
+		if len(record) > 3 && strings.TrimSpace(record[3]) != "" {
+			expiresAt, err := time.Parse(time.RFC3339, strings.TrimSpace(record[3]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid expires_at: %w", line, err)
+			}
+			s.ExpiresAt = &expiresAt
+		}
+		if err := Normalize(&s); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, s)
+	}
+	return entries, nil
+}
+
+// WriteCSV schreibt Einträge im Format von ReadCSV inklusive Kopfzeile.
+func WriteCSV(w io.Writer, entries []models.Suppression) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, s := range entries {
+		expiresAt := ""
+		if s.ExpiresAt != nil {
+			expiresAt = s.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		if err := writer.Write([]string{s.Address, s.Reason, s.Source, expiresAt}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
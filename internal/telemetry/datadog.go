@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	httptrace "gopkg.in/DataDog/dd-trace-go.v1/contrib/net/http"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// datadogProvider sendet Spans an den Datadog-Agent (DD_AGENT_HOST). dd-trace-go schreibt
+// und liest neben den Datadog-Headern auch traceparent, sodass Traces mit OpenTelemetry-Diensten
+// verbunden bleiben.
+type datadogProvider struct {
+	service string
+}
+
+// remoteParentKey speichert einen per Extract gelesenen Span-Kontext im context.Context.
+type remoteParentKey struct{}
+
+func startDatadog(service string) provider {
+	tracer.Start(
+		tracer.WithService(service),
+		tracer.WithEnv(os.Getenv("DD_ENV")),
+	)
+	return datadogProvider{service: service}
+}
+
+type datadogSpan struct {
+	span ddtrace.Span
+}
+
+func (s datadogSpan) SetAttribute(key string, value any) { s.span.SetTag(key, value) }
+
+func (s datadogSpan) End(err error) {
+	if err != nil {
+		s.span.Finish(tracer.WithError(err))
+		return
+	}
+	s.span.Finish()
+}
+
+func (datadogProvider) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	var opts []ddtrace.StartSpanOption
+	if _, ok := tracer.SpanFromContext(ctx); !ok {
+		if parent, ok := ctx.Value(remoteParentKey{}).(ddtrace.SpanContext); ok {
+			opts = append(opts, tracer.ChildOf(parent))
+		}
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, name, opts...)
+	return ctx, datadogSpan{span: span}
+}
+
+func (datadogProvider) inject(ctx context.Context, carrier map[string]string) {
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		tracer.Inject(span.Context(), tracer.TextMapCarrier(carrier))
+	}
+}
+
+func (datadogProvider) extract(carrier map[string]string) context.Context {
+	parent, err := tracer.Extract(tracer.TextMapCarrier(carrier))
+	if err != nil {
+		return context.Background()
+	}
+	return context.WithValue(context.Background(), remoteParentKey{}, parent)
+}
+
+func (datadogProvider) traceID(ctx context.Context) string {
+	span, ok := tracer.SpanFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if w3c, ok := span.Context().(ddtrace.SpanContextW3C); ok {
+		return w3c.TraceID128()
+	}
+	return fmt.Sprintf("%032x", span.Context().TraceID())
+}
+
+func (p datadogProvider) handler(mux *http.ServeMux) http.Handler {
+	return httptrace.WrapHandler(mux, p.service, "", httptrace.WithResourceNamer(func(r *http.Request) string {
+		return routeName(mux, r)
+	}))
+}
+
+func (datadogProvider) shutdown(context.Context) error {
+	tracer.Stop()
+	return nil
+}
@@ -0,0 +1,183 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	prombridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otelProvider erzeugt Spans mit dem OpenTelemetry-SDK. Endpunkt, Header und Sampling werden
+// über die Standard-Variablen OTEL_EXPORTER_OTLP_* und OTEL_TRACES_SAMPLER konfiguriert.
+type otelProvider struct {
+	tracerProvider *sdktrace.TracerProvider
+	// meterProvider exportiert die Prometheus-Metriken aus internal/metrics per OTLP; nil ohne Metrik-Export.
+	meterProvider *sdkmetric.MeterProvider
+	tracer        trace.Tracer
+	propagator    propagation.TextMapPropagator
+}
+
+// startOTLP exportiert Spans und Metriken per OTLP/HTTP.
+func startOTLP(service string) (provider, error) {
+	ctx := context.Background()
+	spanExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create OTLP trace exporter: %w", err)
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not create OTLP metric exporter: %w", err)
+	}
+
+	res, err := serviceResource(service)
+	if err != nil {
+		return nil, err
+	}
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter,
+			sdkmetric.WithProducer(prombridge.NewMetricProducer()))),
+	)
+	return newOTelProvider(res, sdktrace.WithBatcher(spanExporter), meterProvider), nil
+}
+
+// StartWithExporter aktiviert OpenTelemetry mit einem eigenen Span-Exporter, z.B.
+// tracetest.NewInMemoryExporter() in Tests. Spans werden synchron exportiert.
+func StartWithExporter(service string, exporter sdktrace.SpanExporter) (func(), error) {
+	res, err := serviceResource(service)
+	if err != nil {
+		return nil, err
+	}
+	p := newOTelProvider(res, sdktrace.WithSyncer(exporter), nil)
+	current = p
+	return shutdownFunc(p), nil
+}
+
+// serviceResource beschreibt den Dienst; OTEL_SERVICE_NAME und OTEL_RESOURCE_ATTRIBUTES haben Vorrang.
+func serviceResource(service string) (*resource.Resource, error) {
+	res, err := resource.New(context.Background(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not create OpenTelemetry resource: %w", err)
+	}
+	return res, nil
+}
+
+func newOTelProvider(res *resource.Resource, processor sdktrace.TracerProviderOption, meterProvider *sdkmetric.MeterProvider) *otelProvider {
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithResource(res), processor)
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagator)
+	return &otelProvider{
+		tracerProvider: tracerProvider,
+		meterProvider:  meterProvider,
+		tracer:         tracerProvider.Tracer("email-microservice"),
+		propagator:     propagator,
+	}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttribute(key string, value any) { s.span.SetAttributes(toAttribute(key, value)) }
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+func (p *otelProvider) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := p.tracer.Start(ctx, name)
+	return ctx, otelSpan{span: span}
+}
+
+func (p *otelProvider) inject(ctx context.Context, carrier map[string]string) {
+	p.propagator.Inject(ctx, propagation.MapCarrier(carrier))
+}
+
+func (p *otelProvider) extract(carrier map[string]string) context.Context {
+	return p.propagator.Extract(context.Background(), propagation.MapCarrier(carrier))
+}
+
+func (p *otelProvider) traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+func (p *otelProvider) handler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := p.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := p.tracer.Start(ctx, routeName(mux, r),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+func (p *otelProvider) shutdown(ctx context.Context) error {
+	err := p.tracerProvider.Shutdown(ctx)
+	if p.meterProvider != nil {
+		err = errors.Join(err, p.meterProvider.Shutdown(ctx))
+	}
+	return err
+}
+
+// statusRecorder merkt sich den Statuscode der Antwort für den Server-Span.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// toAttribute wandelt einen Wert in ein OpenTelemetry-Attribut um.
+func toAttribute(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,162 @@
+// Package telemetry kapselt Tracing und den Export von Metriken. Als Provider stehen Datadog
+// (dd-trace-go), OpenTelemetry mit OTLP-Export und ein No-op-Provider zur Verfügung. Der
+// Trace-Kontext wird über EmailJob.TraceContext im W3C-Format (traceparent) weitergegeben.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Provider, auswählbar über TELEMETRY_PROVIDER (config.Config.Telemetry).
+const (
+	ProviderDatadog = "datadog"
+	ProviderOTLP    = "otlp"
+	ProviderNone    = "none"
+)
+
+// Span ist ein laufender Span des aktiven Providers.
+type Span interface {
+	// SetAttribute setzt ein Attribut (Datadog: Tag) am Span.
+	SetAttribute(key string, value any)
+	// End beendet den Span; ein Fehler ungleich nil markiert ihn als fehlgeschlagen.
+	End(err error)
+}
+
+// provider ist die Schnittstelle, die Datadog, OpenTelemetry und No-op umsetzen.
+type provider interface {
+	startSpan(ctx context.Context, name string) (context.Context, Span)
+	inject(ctx context.Context, carrier map[string]string)
+	extract(carrier map[string]string) context.Context
+	traceID(ctx context.Context) string
+	handler(mux *http.ServeMux) http.Handler
+	shutdown(ctx context.Context) error
+}
+
+// current ist der mit Start gewählte Provider. Ohne Start ist Telemetrie deaktiviert.
+var current provider = noopProvider{}
+
+// Start initialisiert den Provider name (datadog, otlp oder none; leer bedeutet datadog) für
+// den angegebenen Dienst. Die zurückgegebene Funktion beendet den Provider und exportiert
+// ausstehende Spans.
+func Start(service, name string) (func(), error) {
+	if name == "" {
+		name = ProviderDatadog
+	}
+
+	var p provider
+	var err error
+	switch name {
+	case ProviderDatadog:
+		p = startDatadog(service)
+	case ProviderOTLP:
+		p, err = startOTLP(service)
+	case ProviderNone:
+		p = noopProvider{}
+	default:
+		return nil, fmt.Errorf("ungültiger TELEMETRY_PROVIDER %q: erlaubt sind datadog, otlp oder none", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	current = p
+	return shutdownFunc(p), nil
+}
+
+// shutdownFunc beendet den Provider und setzt auf No-op zurück.
+func shutdownFunc(p provider) func() {
+	return func() {
+		current = noopProvider{}
+		p.shutdown(context.Background())
+	}
+}
+
+// StartSpan startet einen Span als Kind des Spans in ctx. attrs sind Schlüssel-Wert-Paare.
+func StartSpan(ctx context.Context, name string, attrs ...any) (context.Context, Span) {
+	ctx, span := current.startSpan(ctx, name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		span.SetAttribute(fmt.Sprint(attrs[i]), attrs[i+1])
+	}
+	return ctx, span
+}
+
+// Inject schreibt den Trace-Kontext aus ctx in carrier, z.B. EmailJob.TraceContext.
+func Inject(ctx context.Context, carrier map[string]string) {
+	current.inject(ctx, carrier)
+}
+
+// Extract liest den Trace-Kontext aus carrier. Spans, die mit dem Ergebnis gestartet werden,
+// setzen den Trace des Absenders fort.
+func Extract(carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return context.Background()
+	}
+	return current.extract(carrier)
+}
+
+// TraceID liefert die Trace-ID des Spans in ctx als 32-stellige Hex-Zeichenkette (wie in
+// traceparent) für die Korrelation mit Log-Meldungen, oder "" ohne aktiven Trace.
+func TraceID(ctx context.Context) string {
+	return current.traceID(ctx)
+}
+
+// Handler instrumentiert einen ServeMux: jede Anfrage erhält einen Server-Span, benannt nach
+// Methode und Route (z.B. "POST /send-email").
+func Handler(mux *http.ServeMux) http.Handler {
+	return current.handler(mux)
+}
+
+// routeName liefert den Span-Namen einer Anfrage anhand des passenden Musters im ServeMux.
+func routeName(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		pattern = "unmatched"
+	}
+	return r.Method + " " + pattern
+}
+
+type noopProvider struct{}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+func (noopSpan) End(error)                {}
+
+func (noopProvider) startSpan(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+func (noopProvider) inject(context.Context, map[string]string) {}
+func (noopProvider) extract(map[string]string) context.Context {
+	return context.Background()
+}
+func (noopProvider) traceID(context.Context) string          { return "" }
+func (noopProvider) handler(mux *http.ServeMux) http.Handler { return mux }
+func (noopProvider) shutdown(context.Context) error          { return nil }
+
+// Transport instrumentiert ausgehende HTTP-Anfragen: jede Anfrage erhält einen Client-Span mit
+// Methode, Host und Statuscode. Pfad und Query werden nicht erfasst, da sie Postfachadressen
+// enthalten können, und es werden keine Trace-Header an fremde Dienste weitergegeben.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartSpan(req.Context(), "http.request",
+		"http.request.method", req.Method,
+		"server.address", req.URL.Host,
+	)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if resp != nil {
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+	}
+	span.End(err)
+	return resp, err
+}
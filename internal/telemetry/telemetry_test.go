@@ -0,0 +1,152 @@
+package telemetry_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"email-microservice/internal/models"
+	"email-microservice/internal/telemetry"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// startInMemory aktiviert OpenTelemetry mit einem In-Memory-Exporter für die Dauer des Tests.
+func startInMemory(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	stop, err := telemetry.StartWithExporter("email-test", exporter)
+	if err != nil {
+		t.Fatalf("StartWithExporter: %v", err)
+	}
+	t.Cleanup(stop)
+	return exporter
+}
+
+// spanByName liefert den einzigen exportierten Span mit dem Namen.
+func spanByName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	var found []tracetest.SpanStub
+	for _, s := range spans {
+		if s.Name == name {
+			found = append(found, s)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %d spans named %q, want 1 (spans: %v)", len(found), name, spanNames(spans))
+	}
+	return found[0]
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// TestJobTraceContinuesInWorker bildet den Weg eines Jobs nach: Die API publiziert den Job in
+// einem Server-Span und legt den Trace-Kontext in TraceContext ab, der Job wird als JSON
+// übertragen und der Worker setzt den Trace fort.
+func TestJobTraceContinuesInWorker(t *testing.T) {
+	exporter := startInMemory(t)
+
+	var published []byte
+	var apiTraceID string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/send-email", func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := telemetry.StartSpan(r.Context(), "nats.publish", "messaging.destination.name", "mail.jobs.normal", "job.id", int64(42))
+		job := models.EmailJob{ID: 42, AppTag: "rechnungssystem", TraceContext: make(map[string]string)}
+		telemetry.Inject(ctx, job.TraceContext)
+		apiTraceID = telemetry.TraceID(ctx)
+		published, _ = json.Marshal(job)
+		span.End(nil)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	rec := httptest.NewRecorder()
+	telemetry.Handler(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/send-email", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+
+	// Worker: Job aus der Nachricht lesen und den Trace fortsetzen.
+	var job models.EmailJob
+	if err := json.Unmarshal(published, &job); err != nil {
+		t.Fatal(err)
+	}
+	if job.TraceContext["traceparent"] == "" {
+		t.Fatalf("TraceContext has no traceparent: %v", job.TraceContext)
+	}
+	ctx, processSpan := telemetry.StartSpan(telemetry.Extract(job.TraceContext), "email.process", "job.id", job.ID)
+	_, attemptSpan := telemetry.StartSpan(ctx, "graph.attempt", "attempt", 1)
+	attemptSpan.End(nil)
+	workerTraceID := telemetry.TraceID(ctx)
+	processSpan.End(nil)
+
+	spans := exporter.GetSpans()
+	server := spanByName(t, spans, "POST /send-email")
+	publish := spanByName(t, spans, "nats.publish")
+	process := spanByName(t, spans, "email.process")
+	attempt := spanByName(t, spans, "graph.attempt")
+
+	traceID := server.SpanContext.TraceID()
+	for _, s := range []tracetest.SpanStub{publish, process, attempt} {
+		if got := s.SpanContext.TraceID(); got != traceID {
+			t.Errorf("span %q has trace %s, want %s", s.Name, got, traceID)
+		}
+	}
+	if apiTraceID != traceID.String() || workerTraceID != traceID.String() {
+		t.Errorf("TraceID: api %q, worker %q, want %q", apiTraceID, workerTraceID, traceID)
+	}
+
+	parents := []struct {
+		child, parent tracetest.SpanStub
+	}{
+		{publish, server},
+		{process, publish},
+		{attempt, process},
+	}
+	for _, p := range parents {
+		if got := p.child.Parent.SpanID(); got != p.parent.SpanContext.SpanID() {
+			t.Errorf("parent of %q = %s, want %q (%s)", p.child.Name, got, p.parent.Name, p.parent.SpanContext.SpanID())
+		}
+	}
+	if !process.Parent.IsRemote() {
+		t.Error("email.process parent is not marked remote")
+	}
+}
+
+// TestHandlerContinuesIncomingTrace prüft, dass ein traceparent des Clients übernommen wird.
+func TestHandlerContinuesIncomingTrace(t *testing.T) {
+	exporter := startInMemory(t)
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jobs/", func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest(http.MethodGet, "/jobs/1", nil)
+	req.Header.Set("traceparent", traceparent)
+	telemetry.Handler(mux).ServeHTTP(httptest.NewRecorder(), req)
+
+	server := spanByName(t, exporter.GetSpans(), "GET /jobs/")
+	if got := server.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id = %s, want the one from traceparent", got)
+	}
+	if got := server.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span id = %s, want the one from traceparent", got)
+	}
+}
+
+// TestWithoutTraceContext prüft, dass Jobs ohne TraceContext einen neuen Trace beginnen.
+func TestWithoutTraceContext(t *testing.T) {
+	exporter := startInMemory(t)
+
+	_, span := telemetry.StartSpan(telemetry.Extract(nil), "email.process")
+	span.End(nil)
+
+	process := spanByName(t, exporter.GetSpans(), "email.process")
+	if process.Parent.IsValid() {
+		t.Errorf("span without TraceContext has parent %s", process.Parent.SpanID())
+	}
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"email-microservice/internal/db"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/merge"
+	"email-microservice/internal/metrics"
+	"email-microservice/internal/models"
+	natsclient "email-microservice/internal/nats"
+	"email-microservice/internal/telemetry"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fanOutProgressInterval is how often a running fan-out extends the parent message's ack
+// deadline, so JetStream does not redeliver it to another worker while children are
+// still being published.
+const fanOutProgressInterval = 5 * time.Second
+
+// fanOut expands a mail merge job into one job per recipient and publishes them to the
+// lane of the parent job. Each recipient gets its own message, so no recipient sees the
+// addresses of the others. Child jobs are recorded under the parent job ID; repeating a
+// fan-out after a crash reuses the recorded children and JetStream deduplicates the
+// publishes by child job ID.
+func (w *Worker) fanOut(ctx context.Context, job *models.EmailJob, msg *nats.Msg) (string, error) {
+	logger := logging.FromContext(ctx)
+	if job.ID == 0 {
+		logger.Errorf("Mail merge job with appTag '%s' has no job ID, discarding", job.AppTag)
+		w.failedCount++
+		metrics.JobEvent(metrics.EventDeadLettered, job.AppTag, "")
+		msg.Ack()
+		return db.JobStatusFailed, errors.New("mail merge job has no job ID")
+	}
+
+	published, failed := 0, 0
+	lastProgress := time.Now()
+	for i := range job.MergeRecipients {
+		if time.Since(lastProgress) >= fanOutProgressInterval {
+			if err := msg.InProgress(); err != nil {
+				logger.Warnf("Could not extend ack deadline of mail merge job %d: %v", job.ID, err)
+			}
+			// A long fan-out is progress, not a hung worker.
+			w.lastFetch.Store(time.Now().UnixNano())
+			lastProgress = time.Now()
+		}
+
+		child, renderErr := merge.Render(job, i)
+		if renderErr != nil {
+			// Der Empfänger wird mit seinem Fehler protokolliert, damit er im Status sichtbar ist.
+			child = models.EmailJob{ParentID: job.ID, Recipients: []string{job.MergeRecipients[i].Address}, AppTag: job.AppTag}
+			if _, err := w.dbClient.CreateChildJob(job.ID, i, &child, db.JobStatusFailed, renderErr.Error()); err != nil {
+				return w.abortFanOut(logger, job, msg, err)
+			}
+			failed++
+			continue
+		}
+
+		childID, err := w.dbClient.CreateChildJob(job.ID, i, &child, db.JobStatusQueued, "")
+		if err != nil {
+			return w.abortFanOut(logger, job, msg, err)
+		}
+		child.ID = childID
+
+		subject := natsclient.SubjectForPriority(child.Priority)
+		publishCtx, span := telemetry.StartSpan(ctx, "nats.publish", "messaging.destination.name", subject, "job.id", child.ID)
+		child.TraceContext = make(map[string]string)
+		telemetry.Inject(publishCtx, child.TraceContext)
+
+		childJSON, _ := json.Marshal(child)
+		msgID := fmt.Sprintf("mail-job-%d", child.ID)
+		_, err = w.js.Publish(subject, childJSON, nats.MsgId(msgID))
+		span.End(err)
+		if err != nil {
+			return w.abortFanOut(logger, job, msg, fmt.Errorf("failed to publish child job %d: %w", child.ID, err))
+		}
+		published++
+	}
+
+	logger.Infof("Expanded mail merge job %d into %d jobs (%d recipients failed to render)", job.ID, published, failed)
+	w.finishJob(job, db.JobStatusExpanded, "")
+	msg.Ack()
+	return db.JobStatusExpanded, nil
+}
+
+// abortFanOut releases the parent job so that the fan-out is retried later.
+func (w *Worker) abortFanOut(logger *logging.Logger, job *models.EmailJob, msg *nats.Msg, err error) (string, error) {
+	logger.Errorf("Fan-out of mail merge job %d failed, releasing for later retry: %v", job.ID, err)
+	w.releaseJob(job)
+	msg.Nak()
+	return db.JobStatusQueued, err
+}
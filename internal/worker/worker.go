@@ -1,49 +1,93 @@
 package worker
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"email-microservice/internal/address"
+	"email-microservice/internal/cache"
 	"email-microservice/internal/db"
 	"email-microservice/internal/graph"
+	"email-microservice/internal/logging"
+	"email-microservice/internal/metrics"
 	"email-microservice/internal/models"
 	natsclient "email-microservice/internal/nats"
+	"email-microservice/internal/policy"
+	"email-microservice/internal/telemetry"
 
 	"github.com/nats-io/nats.go"
 )
 
+// statusSkipped is reported in the trace for messages that are not processed, e.g. because
+// the job was cancelled or already delivered.
+const statusSkipped = "skipped"
+
 const (
 	ConsumerName     = "EMAIL_WORKER"
+	ConsumerNameHigh = "EMAIL_WORKER_HIGH"
+	ConsumerNameLow  = "EMAIL_WORKER_LOW"
 	maxRetries       = 3
-	SendersTableName = "senders"
+
+	// laneFetchWait is how long a single fetch waits on an empty lane before the
+	// worker moves on to the next lane.
+	laneFetchWait = 250 * time.Millisecond
 )
 
+// lane is a priority lane backed by its own durable pull consumer. In every round the
+// worker takes up to weight messages from each lane, starting with the highest priority,
+// so a backlog in a lower lane can delay high-priority jobs by at most one round.
+type lane struct {
+	priority string
+	consumer string
+	weight   int
+	sub      *nats.Subscription
+}
+
+// defaultLanes defines the lanes in the order they are served within a round.
+var defaultLanes = []lane{
+	{priority: natsclient.PriorityHigh, consumer: ConsumerNameHigh, weight: 6},
+	{priority: natsclient.PriorityNormal, consumer: ConsumerName, weight: 3},
+	{priority: natsclient.PriorityLow, consumer: ConsumerNameLow, weight: 1},
+}
+
 type Worker struct {
-	js             nats.JetStreamContext
-	sub            *nats.Subscription
-	graphClient    *graph.Client
-	dbClient       *db.Client
+	js        nats.JetStreamContext
+	lanes     []lane
+	graphPool *graph.Pool
+	dbClient  *db.Client
+	// cache holds senders, tenants and recipient policies; see cache.Cache.Watch.
+	cache          *cache.Cache
 	processedCount uint64
 	throttledCount uint64
 	failedCount    uint64
+	// lastFetch is the time of the last fetch that reached the server, in Unix nanoseconds.
+	lastFetch atomic.Int64
 }
 
-func New(js nats.JetStreamContext, graphClient *graph.Client, dbClient *db.Client) (*Worker, error) {
-	sub, err := js.PullSubscribe(natsclient.EmailToSend, ConsumerName)
-	if err != nil {
-		return nil, err
+func New(js nats.JetStreamContext, graphPool *graph.Pool, dbClient *db.Client, configCache *cache.Cache) (*Worker, error) {
+	lanes := make([]lane, len(defaultLanes))
+	for i, l := range defaultLanes {
+		sub, err := js.PullSubscribe(natsclient.SubjectForPriority(l.priority), l.consumer)
+		if err != nil {
+			return nil, fmt.Errorf("could not subscribe to %s lane: %w", l.priority, err)
+		}
+		l.sub = sub
+		lanes[i] = l
 	}
 	return &Worker{
 		js:             js,
-		sub:            sub,
-		graphClient:    graphClient,
+		lanes:          lanes,
+		graphPool:      graphPool,
 		dbClient:       dbClient,
+		cache:          configCache,
 		processedCount: 0,
 		throttledCount: 0,
 		failedCount:    0,
@@ -51,18 +95,31 @@ func New(js nats.JetStreamContext, graphClient *graph.Client, dbClient *db.Clien
 }
 
 func (w *Worker) Run() {
-	log.Println("Worker started. Waiting for email jobs...")
+	logging.Infof("Worker started. Waiting for email jobs...")
 	go w.logSummary()
 
 	for {
-		msgs, err := w.sub.Fetch(1, nats.MaxWait(10*time.Second))
+		for _, l := range w.lanes {
+			w.drainLane(l)
+		}
+	}
+}
+
+// drainLane processes up to l.weight messages from the lane, one at a time, and
+// returns early as soon as the lane is empty.
+func (w *Worker) drainLane(l lane) {
+	for i := 0; i < l.weight; i++ {
+		msgs, err := l.sub.Fetch(1, nats.MaxWait(laneFetchWait))
+		if err == nil || err == nats.ErrTimeout {
+			w.lastFetch.Store(time.Now().UnixNano())
+		}
 		if err != nil {
 			if err == nats.ErrTimeout {
-				continue
+				return
 			}
-			log.Printf("Error fetching message: %v", err)
+			logging.Errorf("Could not fetch message from %s lane: %v", l.priority, err)
 			time.Sleep(2 * time.Second)
-			continue
+			return
 		}
 		for _, msg := range msgs {
 			w.processMessage(msg)
@@ -70,64 +127,150 @@ func (w *Worker) Run() {
 	}
 }
 
+// LastFetch returns the time of the last successful fetch from any lane, including fetches
+// that returned no messages. It is zero before the first fetch.
+func (w *Worker) LastFetch() time.Time {
+	n := w.lastFetch.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
 func (w *Worker) logSummary() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
 	for range ticker.C {
 		streamInfo, sErr := w.js.StreamInfo(natsclient.StreamName)
-		consumerInfo, cErr := w.js.ConsumerInfo(natsclient.StreamName, ConsumerName)
 
-		log.Println("----------- WORKER SUMMARY -----------")
-		if sErr == nil && cErr == nil {
-			log.Printf("Queue Status -> Total in Stream: %d, Pending for Workers: %d", streamInfo.State.Msgs, consumerInfo.NumPending)
+		logging.Infof("----------- WORKER SUMMARY -----------")
+		if sErr == nil {
+			logging.Infof("Queue Status -> Total in Stream: %d", streamInfo.State.Msgs)
 		} else {
-			log.Println("Queue Status -> Could not retrieve NATS stream/consumer info")
+			logging.Infof("Queue Status -> Could not retrieve NATS stream info")
 		}
-		log.Printf("This Worker -> Processed: %d, Throttled: %d, Failed: %d", w.processedCount, w.throttledCount, w.failedCount)
-		log.Println("------------------------------------")
+		for _, l := range w.lanes {
+			consumerInfo, cErr := w.js.ConsumerInfo(natsclient.StreamName, l.consumer)
+			if cErr != nil {
+				logging.Infof("Lane %s -> Could not retrieve NATS consumer info", l.priority)
+				continue
+			}
+			logging.Infof("Lane %s -> Pending for Workers: %d", l.priority, consumerInfo.NumPending)
+			metrics.ConsumerPending.WithLabelValues(l.consumer).Set(float64(consumerInfo.NumPending))
+			metrics.ConsumerAckPending.WithLabelValues(l.consumer).Set(float64(consumerInfo.NumAckPending))
+		}
+		logging.Infof("This Worker -> Processed: %d, Throttled: %d, Failed: %d", w.processedCount, w.throttledCount, w.failedCount)
+		logging.Infof("------------------------------------")
 	}
 }
 
-func (w *Worker) getSenderByAppTag(appTag string) (*models.Sender, error) {
-	if appTag == "" {
-		return nil, fmt.Errorf("appTag is empty")
+// graphClientFor returns the Graph client for the tenant of the sender; senders without a
+// tenant use the default credentials.
+func (w *Worker) graphClientFor(sender *models.Sender) (*graph.Client, error) {
+	if sender.TenantID == nil {
+		return w.graphPool.Default(), nil
 	}
-
-	var senders []models.Sender
-	whereClause := "app_tag = $1"
-	err := w.dbClient.Read(SendersTableName, &senders, whereClause, appTag)
+	tenant, err := w.cache.Tenant(*sender.TenantID)
 	if err != nil {
-		return nil, fmt.Errorf("database query failed for appTag '%s': %w", appTag, err)
-	}
-
-	if len(senders) == 0 {
-		return nil, fmt.Errorf("no sender found for appTag '%s'", appTag)
+		return nil, err
 	}
-
-	return &senders[0], nil
+	return w.graphPool.Get(tenant), nil
 }
 
 func (w *Worker) processMessage(msg *nats.Msg) {
 	var job models.EmailJob
 	if err := json.Unmarshal(msg.Data, &job); err != nil {
-		log.Printf("ERROR: Could not unmarshal message, discarding: %v", err)
+		logging.Errorf("Could not unmarshal message, discarding: %v", err)
 		msg.Ack()
 		return
 	}
 
-	sender, err := w.getSenderByAppTag(job.AppTag)
+	// Continue the trace started by the API from the W3C trace context in the job.
+	ctx, span := telemetry.StartSpan(telemetry.Extract(job.TraceContext), "email.process",
+		"job.id", job.ID, "app_tag", job.AppTag, "messaging.destination.name", msg.Subject)
+	status, err := w.processJob(ctx, &job, msg)
+	span.SetAttribute("job.status", status)
+	span.End(err)
+}
+
+// processJob sends a single job and acks or naks the message. It returns the resulting
+// job status for the trace; jobs released for a later retry report "queued", and the
+// error describes why the job did not reach the status "sent".
+func (w *Worker) processJob(ctx context.Context, job *models.EmailJob, msg *nats.Msg) (string, error) {
+	logger := jobLogger(ctx, job)
+	ctx = logging.NewContext(ctx, logger)
+
+	if !w.claimJob(ctx, job, msg) {
+		return statusSkipped, nil
+	}
+
+	if len(job.MergeRecipients) > 0 {
+		return w.fanOut(ctx, job, msg)
+	}
+
+	_, lookupSpan := telemetry.StartSpan(ctx, "db.lookup_sender", "app_tag", job.AppTag)
+	sender, err := w.cache.Sender(job.AppTag)
+	lookupSpan.End(err)
 	if err != nil {
-		log.Printf("ERROR: Permanent failure for job with appTag '%s', discarding: %v", job.AppTag, err)
+		logger.Errorf("Permanent failure for job with appTag '%s', discarding: %v", job.AppTag, err)
 		w.failedCount++
+		metrics.JobEvent(metrics.EventDeadLettered, job.AppTag, "")
+		w.finishJob(job, db.JobStatusFailed, err.Error())
 		msg.Ack()
-		return
+		return db.JobStatusFailed, err
+	}
+	logger = logger.With(logging.FieldSender, sender.Email)
+
+	graphClient, err := w.graphClientFor(sender)
+	if err != nil {
+		logger.Errorf("Could not load tenant of sender '%s', releasing for later retry: %v", sender.Email, err)
+		w.releaseJob(job)
+		msg.Nak()
+		return db.JobStatusQueued, err
+	}
+
+	// Defence in depth: the API already enforces the policy, but jobs may have been
+	// queued before a rule was added or published without going through the API.
+	rules, err := w.cache.PolicyRules(job.AppTag)
+	if err != nil {
+		logger.Errorf("Could not load recipient policy for appTag '%s', releasing for later retry: %v", job.AppTag, err)
+		w.releaseJob(job)
+		msg.Nak()
+		return db.JobStatusQueued, err
+	}
+	if err := checkPolicy(policy.FromRules(rules), job); err != nil {
+		logger.Errorf("Permanent failure for job with appTag '%s', discarding: %v", job.AppTag, err)
+		w.failedCount++
+		metrics.JobEvent(metrics.EventDeadLettered, job.AppTag, sender.Email)
+		w.finishJob(job, db.JobStatusFailed, err.Error())
+		msg.Ack()
+		return db.JobStatusFailed, err
+	}
+
+	if !job.IgnoreSuppression {
+		suppressed, err := w.dropSuppressedRecipients(job)
+		if err != nil {
+			logger.Errorf("Could not check suppression list for job with appTag '%s', releasing for later retry: %v", job.AppTag, err)
+			w.releaseJob(job)
+			msg.Nak()
+			return db.JobStatusQueued, err
+		}
+		if len(suppressed) > 0 {
+			logger.Infof("Dropped %d suppressed recipients from job with appTag '%s'", len(suppressed), job.AppTag)
+		}
+		if len(job.Recipients)+len(job.CcRecipients)+len(job.BccRecipients) == 0 {
+			logger.Infof("All recipients of job with appTag '%s' are suppressed, discarding", job.AppTag)
+			w.finishJob(job, db.JobStatusSuppressed, "all recipients are on the suppression list")
+			msg.Ack()
+			return db.JobStatusSuppressed, nil
+		}
 	}
 
 	allRecipients := append(job.Recipients, job.CcRecipients...)
 	allRecipients = append(allRecipients, job.BccRecipients...)
 
-	log.Printf("Processing email with appTag '%s' from sender '%s' to %v", job.AppTag, sender.Email, allRecipients)
+	logger.Infof("Processing email with appTag '%s' from sender '%s' to %v", job.AppTag, sender.Email, allRecipients)
 
 	var bodyContent string
 	var contentType string
@@ -140,14 +283,19 @@ func (w *Worker) processMessage(msg *nats.Msg) {
 	}
 
 	// KORREKTUR: Anhänge von models.Attachment (mit base64-String) zu graph.Attachment (mit byte slice) konvertieren.
+	_, decodeSpan := telemetry.StartSpan(ctx, "attachments.decode", "attachments.count", len(job.Attachments))
 	graphAttachments := make([]graph.Attachment, len(job.Attachments))
 	for i, att := range job.Attachments {
 		decodedContent, err := base64.StdEncoding.DecodeString(att.ContentBytes)
 		if err != nil {
-			log.Printf("ERROR: Permanent failure for job with appTag '%s', could not decode attachment '%s': %v", job.AppTag, att.Name, err)
+			err = fmt.Errorf("could not decode attachment '%s': %v", att.Name, err)
+			decodeSpan.End(err)
+			logger.Errorf("Permanent failure for job with appTag '%s', %v", job.AppTag, err)
 			w.failedCount++
+			metrics.JobEvent(metrics.EventDeadLettered, job.AppTag, sender.Email)
+			w.finishJob(job, db.JobStatusFailed, err.Error())
 			msg.Ack()
-			return
+			return db.JobStatusFailed, err
 		}
 
 		graphAttachments[i] = graph.Attachment{
@@ -156,44 +304,287 @@ func (w *Worker) processMessage(msg *nats.Msg) {
 			MimeType: att.ContentType,
 		}
 	}
+	decodeSpan.End(nil)
 
+	// lastErr is the reason of the last failed attempt, reported when the job is released.
+	var lastErr error
 	for attempt := 0; attempt < maxRetries; attempt++ {
+		attemptCtx, attemptSpan := telemetry.StartSpan(ctx, "graph.attempt", "attempt", attempt+1, "sender", sender.Email)
 		// GEÄNDERT: sender.UserID wird nicht mehr übergeben. Das konvertierte Slice 'graphAttachments' wird hier verwendet.
-		resp, err := w.graphClient.SendEmail(job.Recipients, job.CcRecipients, job.BccRecipients, job.Subject, bodyContent, contentType, graphAttachments)
+		resp, err := graphClient.SendEmail(attemptCtx, toGraphRecipients(job.Recipients), toGraphRecipients(job.CcRecipients), toGraphRecipients(job.BccRecipients), job.Subject, bodyContent, contentType, graphAttachments, messageHeaders(job), messageOptions(job, sender))
+		attemptLogger := logger.With(logging.FieldAttempt, attempt+1)
+		if resp != nil {
+			attemptSpan.SetAttribute("http.response.status_code", resp.StatusCode)
+			attemptLogger = attemptLogger.With(logging.FieldStatusCode, resp.StatusCode, logging.FieldGraphRequestID, resp.Header.Get("request-id"))
+		}
+		attemptSpan.End(attemptError(resp, err))
+		if errors.Is(err, graph.ErrInvalidHeaders) || errors.Is(err, graph.ErrNoMailbox) {
+			attemptLogger.Errorf("Permanent failure for job with appTag '%s', discarding: %v", job.AppTag, err)
+			w.failedCount++
+			metrics.JobEvent(metrics.EventDeadLettered, job.AppTag, sender.Email)
+			w.finishJob(job, db.JobStatusFailed, err.Error())
+			msg.Ack()
+			return db.JobStatusFailed, err
+		}
 		if err != nil {
-			log.Printf("ERROR (Attempt %d) sending email from '%s' to %v: %v", attempt+1, sender.Email, allRecipients, err)
+			lastErr = err
+			attemptLogger.Warnf("Attempt %d failed sending email from '%s' to %v: %v", attempt+1, sender.Email, allRecipients, err)
 			time.Sleep(time.Duration(2+attempt) * time.Second)
 			continue
 		}
 
 		if resp.StatusCode == http.StatusAccepted {
 			w.processedCount++
-			log.Printf("Successfully sent email from '%s' to %v", sender.Email, allRecipients)
+			metrics.JobEvent(metrics.EventSent, job.AppTag, sender.Email)
+			observeQueueTime(msg, job.AppTag)
+			attemptLogger.Infof("Successfully sent email from '%s' to %v", sender.Email, allRecipients)
+			w.finishJob(job, db.JobStatusSent, "")
 			msg.Ack()
 			resp.Body.Close()
-			return
+			return db.JobStatusSent, nil
 		}
 
 		if resp.StatusCode == http.StatusTooManyRequests {
 			w.throttledCount++
+			lastErr = errors.New("throttled by Graph (429)")
+			metrics.JobEvent(metrics.EventThrottled, job.AppTag, sender.Email)
 			retryAfterStr := resp.Header.Get("Retry-After")
 			retryAfter, _ := strconv.Atoi(retryAfterStr)
 			if retryAfter <= 0 {
 				retryAfter = 5 // Fallback
 			}
-			log.Printf("WARN: Throttled (429) on attempt %d for sender '%s'. Waiting %d seconds...", attempt+1, sender.Email, retryAfter)
+			attemptLogger.Warnf("Throttled (429) on attempt %d for sender '%s'. Waiting %d seconds...", attempt+1, sender.Email, retryAfter)
 			resp.Body.Close()
 			time.Sleep(time.Duration(retryAfter) * time.Second)
 			continue
 		}
 
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("ERROR: Unexpected status %d on attempt %d from sender '%s': %s", resp.StatusCode, attempt+1, sender.Email, string(bodyBytes))
+		apiErr := graph.ReadError(resp)
 		resp.Body.Close()
+		if apiErr.PermissionDenied() {
+			errMsg := fmt.Sprintf("mailbox '%s' is not allowed to send as '%s' (sender '%s'): %v", sender.Mailbox, sender.Email, sender.SenderAddress, apiErr)
+			if sender.Mailbox == "" {
+				errMsg = fmt.Sprintf("the app is not allowed to send from the configured mailbox: %v", apiErr)
+			}
+			attemptLogger.Errorf("Permanent failure for job with appTag '%s', discarding: %s", job.AppTag, errMsg)
+			w.failedCount++
+			metrics.JobEvent(metrics.EventDeadLettered, job.AppTag, sender.Email)
+			w.finishJob(job, db.JobStatusFailed, errMsg)
+			msg.Ack()
+			return db.JobStatusFailed, errors.New(errMsg)
+		}
+		lastErr = apiErr
+		attemptLogger.Errorf("Unexpected status %d on attempt %d from sender '%s': %v", resp.StatusCode, attempt+1, sender.Email, apiErr)
 		break
 	}
 
 	w.failedCount++
-	log.Printf("ERROR: All retries failed for email from '%s' to %v. Releasing job for later retry.", sender.Email, allRecipients)
+	metrics.JobEvent(metrics.EventFailed, job.AppTag, sender.Email)
+	logger.Errorf("All retries failed for email from '%s' to %v. Releasing job for later retry.", sender.Email, allRecipients)
+	w.releaseJob(job)
 	msg.Nak()
+	return db.JobStatusQueued, lastErr
+}
+
+// jobLogger returns a logger with the correlation fields of the job and the trace in ctx.
+func jobLogger(ctx context.Context, job *models.EmailJob) *logging.Logger {
+	return logging.With(
+		logging.FieldJobID, job.ID,
+		logging.FieldAppTag, job.AppTag,
+		logging.FieldCorrelationID, job.CorrelationID,
+		logging.FieldTraceID, telemetry.TraceID(ctx),
+	)
+}
+
+// attemptError describes the outcome of a Graph attempt for its span; anything other than
+// 202 Accepted counts as an error.
+func attemptError(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("graph returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// observeQueueTime records the time from publishing the message to the stream until the
+// mail was accepted by Graph.
+func observeQueueTime(msg *nats.Msg, appTag string) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return
+	}
+	metrics.QueueTime.WithLabelValues(appTag).Observe(time.Since(meta.Timestamp).Seconds())
+}
+
+// checkPolicy returns the first recipient of the job that violates the policy.
+func checkPolicy(p *policy.Policy, job *models.EmailJob) error {
+	for _, recipients := range [][]string{job.Recipients, job.CcRecipients, job.BccRecipients} {
+		if violations := p.CheckAll(recipients); len(violations) > 0 {
+			return violations[0].Err
+		}
+	}
+	return nil
+}
+
+// dropSuppressedRecipients removes all recipients on the suppression list from the job,
+// records them on the job and returns their addresses.
+func (w *Worker) dropSuppressedRecipients(job *models.EmailJob) ([]string, error) {
+	var keys []string
+	for _, recipients := range [][]string{job.Recipients, job.CcRecipients, job.BccRecipients} {
+		for _, raw := range recipients {
+			if addr, err := address.Parse(raw); err == nil {
+				keys = append(keys, addr.Key())
+			}
+		}
+	}
+
+	active, err := w.dbClient.ActiveSuppressions(keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+
+	var dropped []string
+	filter := func(recipients []string) []string {
+		var kept []string
+		for _, raw := range recipients {
+			if addr, err := address.Parse(raw); err == nil {
+				if _, ok := active[addr.Key()]; ok {
+					dropped = append(dropped, addr.Email)
+					continue
+				}
+			}
+			kept = append(kept, raw)
+		}
+		return kept
+	}
+	job.Recipients = filter(job.Recipients)
+	job.CcRecipients = filter(job.CcRecipients)
+	job.BccRecipients = filter(job.BccRecipients)
+
+	if job.ID != 0 {
+		if err := w.dbClient.RecordSuppressedRecipients(job.ID, dropped); err != nil {
+			logging.Errorf("%v", err)
+		}
+	}
+	return dropped, nil
+}
+
+// messageHeaders returns the internet message headers stamped on the outgoing mail: the
+// custom headers of the job plus the job ID and app tag. The job ID header lets the bounce
+// processor correlate non-delivery reports.
+func messageHeaders(job *models.EmailJob) map[string]string {
+	headers := make(map[string]string, len(job.Headers)+2)
+	for name, value := range job.Headers {
+		headers[name] = value
+	}
+	headers[graph.HeaderAppTag] = job.AppTag
+	if job.ID != 0 {
+		headers[graph.HeaderJobID] = strconv.FormatInt(job.ID, 10)
+	}
+	return headers
+}
+
+// messageOptions returns the optional message properties of the job. Reply-To, importance
+// and saveToSentItems fall back to the defaults of the sender when the job does not set them;
+// mailbox, From and Sender always come from the sender.
+func messageOptions(job *models.EmailJob, sender *models.Sender) graph.MessageOptions {
+	opts := graph.MessageOptions{
+		ReplyTo:                    toGraphRecipients(job.ReplyTo),
+		Importance:                 job.Importance,
+		IsReadReceiptRequested:     job.RequestReadReceipt,
+		IsDeliveryReceiptRequested: job.RequestDeliveryReceipt,
+		Categories:                 job.Categories,
+		SaveToSentItems:            sender.SaveToSentItems,
+		Mailbox:                    sender.Mailbox,
+	}
+	// Senders of another tenant have no default mailbox; send through their From address.
+	if sender.TenantID != nil && opts.Mailbox == "" {
+		opts.Mailbox = sender.Email
+	}
+	// Send as the From address if the sender sends through a different mailbox.
+	if sender.Mailbox != "" && !strings.EqualFold(sender.Mailbox, sender.Email) {
+		opts.From = &graph.Recipient{Address: sender.Email}
+	}
+	if sender.SenderAddress != "" {
+		opts.Sender = &graph.Recipient{Address: sender.SenderAddress}
+	}
+	if len(opts.ReplyTo) == 0 && sender.ReplyTo != "" {
+		opts.ReplyTo = toGraphRecipients([]string{sender.ReplyTo})
+	}
+	if opts.Importance == "" {
+		opts.Importance = sender.Importance
+	}
+	if job.SaveToSentItems != nil {
+		opts.SaveToSentItems = *job.SaveToSentItems
+	}
+	return opts
+}
+
+// toGraphRecipients converts recipients like "Name <a@b.de>" into graph recipients with a display
+// name. Addresses are validated by the API; anything unparsable is passed through unchanged.
+func toGraphRecipients(recipients []string) []graph.Recipient {
+	result := make([]graph.Recipient, 0, len(recipients))
+	for _, raw := range recipients {
+		addr, err := address.Parse(raw)
+		if err != nil {
+			result = append(result, graph.Recipient{Address: raw})
+			continue
+		}
+		result = append(result, graph.Recipient{Address: addr.Email, Name: addr.Name})
+	}
+	return result
+}
+
+// claimJob marks the job as being sent. It returns false if the message must not be
+// processed, e.g. because the job was cancelled or already delivered; the message is
+// then acked (or nacked on database errors) here.
+func (w *Worker) claimJob(ctx context.Context, job *models.EmailJob, msg *nats.Msg) bool {
+	logger := logging.FromContext(ctx)
+	// Jobs without an ID were published without being recorded and cannot be cancelled.
+	if job.ID == 0 {
+		return true
+	}
+
+	claimed, status, err := w.dbClient.ClaimJob(job.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrJobNotFound) {
+			logger.Warnf("Job %d is not recorded in the database, sending anyway", job.ID)
+			return true
+		}
+		logger.Errorf("Could not claim job %d, releasing for later retry: %v", job.ID, err)
+		msg.Nak()
+		return false
+	}
+	if !claimed {
+		logger.Infof("Skipping job %d with status '%s'", job.ID, status)
+		msg.Ack()
+		return false
+	}
+	return true
+}
+
+// finishJob records the final status of a job in the database.
+func (w *Worker) finishJob(job *models.EmailJob, status, errorMessage string) {
+	if job.ID == 0 {
+		return
+	}
+	if err := w.dbClient.FinishJob(job.ID, status, errorMessage); err != nil {
+		jobLogger(context.Background(), job).Errorf("%v", err)
+	}
+}
+
+// releaseJob puts a claimed job back into the queued state so it can be cancelled
+// again until it is redelivered.
+func (w *Worker) releaseJob(job *models.EmailJob) {
+	if job.ID == 0 {
+		return
+	}
+	if err := w.dbClient.ReleaseJob(job.ID); err != nil {
+		jobLogger(context.Background(), job).Errorf("%v", err)
+	}
 }